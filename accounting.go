@@ -0,0 +1,167 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+// Jobs which reached a final state, counted per partition and state,
+// and the CPU time they were allocated per partition
+type AccountingMetrics struct {
+	jobs       map[string]map[string]float64
+	cpuSeconds map[string]float64
+}
+
+// Layout of the --starttime and --endtime of sacct
+const sacctTimeLayout = "2006-01-02T15:04:05"
+
+// Execute the sacct command and return the jobs which completed between start and end
+func AccountingData(start time.Time, end time.Time) ([]byte, error) {
+	args := []string{"-a", "-X", "-n", "-P",
+		"--format=JobID,State,Partition,AllocCPUS,Elapsed",
+		"--state=BF,CA,CD,DL,F,NF,OOM,PR,TO",
+		"--starttime=" + start.Format(sacctTimeLayout), "--endtime=" + end.Format(sacctTimeLayout)}
+	return RunCommand("sacct", "sacct", args...)
+}
+
+// ParseAccountingMetrics takes the output of sacct (JobID|State|Partition|AllocCPUS|Elapsed)
+// It returns the number of jobs per partition and state, and the CPU seconds per partition
+func ParseAccountingMetrics(input []byte) *AccountingMetrics {
+	am := AccountingMetrics{
+		jobs:       make(map[string]map[string]float64),
+		cpuSeconds: make(map[string]float64),
+	}
+	for _, line := range strings.Split(string(input), "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) < 3 {
+			continue
+		}
+		// Job steps (e.g. "4007.batch" or "4007.0") would count the job
+		// twice, -X should leave them out already
+		if strings.Contains(fields[0], ".") {
+			continue
+		}
+		// e.g. "CANCELLED by 1000"
+		state := strings.ToLower(strings.Fields(fields[1] + " ")[0])
+		partition := strings.TrimSpace(fields[2])
+		if state == "" {
+			continue
+		}
+		if _, ok := am.jobs[partition]; !ok {
+			am.jobs[partition] = make(map[string]float64)
+		}
+		am.jobs[partition][state]++
+		if len(fields) > 4 {
+			cpus, _ := strconv.ParseFloat(strings.TrimSpace(fields[3]), 64)
+			am.cpuSeconds[partition] += cpus * ParseSlurmDuration(strings.TrimSpace(fields[4]))
+		}
+	}
+	return &am
+}
+
+/*
+ * Implement the Prometheus Collector interface and feed the
+ * Slurm accounting metrics into it.
+ * https://godoc.org/github.com/prometheus/client_golang/prometheus#Collector
+ */
+
+func NewAccountingCollector() *AccountingCollector {
+	return &AccountingCollector{
+		window:     *sacctWindow,
+		lag:        *sacctLag,
+		totals:     &AccountingMetrics{jobs: make(map[string]map[string]float64), cpuSeconds: make(map[string]float64)},
+		completed:  prometheus.NewDesc("slurm_jobs_completed_total", "Jobs completed since the exporter started per partition and state", []string{"partition", "state"}, nil),
+		cpuSeconds: prometheus.NewDesc("slurm_cpu_seconds_total", "CPU seconds allocated to the jobs completed since the exporter started per partition", []string{"partition"}, nil),
+	}
+}
+
+// Every scrape queries sacct for the jobs completed since the end of the
+// previous query and adds them to the totals, so that every job is counted
+// once whatever the scrape interval. The first query covers the window.
+// The queries end lag before the scrape, slurmctld sends the records of the
+// completed jobs to slurmdbd asynchronously and a job recorded after the
+// query of its end time would never be counted.
+type AccountingCollector struct {
+	window  time.Duration
+	lag     time.Duration
+	mutex   sync.Mutex
+	lastEnd time.Time
+	totals  *AccountingMetrics
+
+	completed  *prometheus.Desc
+	cpuSeconds *prometheus.Desc
+}
+
+// update queries the jobs completed up to now minus the lag and adds them to
+// the totals, a failed query is repeated from the same start on the next scrape
+func (ac *AccountingCollector) update(now time.Time) {
+	end := now.Add(-ac.lag).Truncate(time.Second)
+	start := end.Add(-ac.window)
+	if !ac.lastEnd.IsZero() {
+		// The times of sacct are inclusive and have a resolution of seconds
+		start = ac.lastEnd.Add(time.Second)
+	}
+	if end.Before(start) {
+		return
+	}
+	out, err := AccountingData(start, end)
+	if err != nil {
+		if err != ErrPermissionDenied {
+			log.Errorf("Collector sacct: %v", err)
+		}
+		return
+	}
+	am := ParseAccountingMetrics(out)
+	for partition, states := range am.jobs {
+		if _, ok := ac.totals.jobs[partition]; !ok {
+			ac.totals.jobs[partition] = make(map[string]float64)
+		}
+		for state, count := range states {
+			ac.totals.jobs[partition][state] += count
+		}
+	}
+	for partition, seconds := range am.cpuSeconds {
+		ac.totals.cpuSeconds[partition] += seconds
+	}
+	ac.lastEnd = end
+}
+
+// Send all metric descriptions
+func (ac *AccountingCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ac.completed
+	ch <- ac.cpuSeconds
+}
+
+func (ac *AccountingCollector) Collect(ch chan<- prometheus.Metric) {
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+	ac.update(time.Now())
+	for partition, states := range ac.totals.jobs {
+		for state, count := range states {
+			ch <- prometheus.MustNewConstMetric(ac.completed, prometheus.CounterValue, count, partition, state)
+		}
+	}
+	for partition, seconds := range ac.totals.cpuSeconds {
+		ch <- prometheus.MustNewConstMetric(ac.cpuSeconds, prometheus.CounterValue, seconds, partition)
+	}
+}
@@ -0,0 +1,125 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountingMetrics(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sacct.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	am := ParseAccountingMetrics(data)
+	t.Logf("%+v", am)
+
+	assert.Equal(t, 2.0, am.jobs["cpu"]["completed"])
+	assert.Equal(t, 1.0, am.jobs["cpu"]["failed"])
+	assert.Equal(t, 2.0, am.jobs["gpu"]["completed"])
+	assert.Equal(t, 1.0, am.jobs["gpu"]["cancelled"])
+	assert.Equal(t, 1.0, am.jobs["gpu"]["timeout"])
+	assert.NotContains(t, am.jobs["cpu"], "timeout")
+
+	// 4*600 + 8*3600 + 2*30 and 16*86400 + 32*7200 + 100, without the steps
+	assert.Equal(t, 31260.0, am.cpuSeconds["cpu"])
+	assert.Equal(t, 1612900.0, am.cpuSeconds["gpu"])
+}
+
+func TestAccountingCollectorTotals(t *testing.T) {
+	calls := stubCommands(t, map[string]string{"sacct": "test_data/sacct.txt"})
+	ac := NewAccountingCollector()
+	ac.window = 5 * time.Minute
+	ac.lag = 0
+	now := time.Date(2026, 3, 4, 10, 0, 0, 500000000, time.Local)
+
+	// The first query covers the window
+	ac.update(now)
+	assert.Contains(t, calls["sacct"], "--starttime=2026-03-04T09:55:00")
+	assert.Contains(t, calls["sacct"], "--endtime=2026-03-04T10:00:00")
+	assert.Equal(t, 2.0, ac.totals.jobs["cpu"]["completed"])
+
+	// The next query starts after the end of the previous one and adds to the totals
+	ac.update(now.Add(time.Minute))
+	assert.Contains(t, calls["sacct"], "--starttime=2026-03-04T10:00:01")
+	assert.Contains(t, calls["sacct"], "--endtime=2026-03-04T10:01:00")
+	assert.Equal(t, 4.0, ac.totals.jobs["cpu"]["completed"])
+	assert.Equal(t, 62520.0, ac.totals.cpuSeconds["cpu"])
+
+	// A failed query is repeated from the same start
+	stubCommands(t, map[string]string{})
+	ac.update(now.Add(2 * time.Minute))
+	assert.Equal(t, 4.0, ac.totals.jobs["cpu"]["completed"])
+	calls = stubCommands(t, map[string]string{"sacct": "test_data/sacct.txt"})
+	ac.update(now.Add(3 * time.Minute))
+	assert.Contains(t, calls["sacct"], "--starttime=2026-03-04T10:01:01")
+	assert.Equal(t, 6.0, ac.totals.jobs["cpu"]["completed"])
+}
+
+func TestAccountingCollectorLag(t *testing.T) {
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.Local)
+	// Jobs by their end time, 4102 reaches slurmdbd only 30 seconds after its end
+	type record struct {
+		line     string
+		end      time.Time
+		recorded time.Time
+	}
+	records := []record{
+		{"4101|COMPLETED|cpu|4|00:10:00", now.Add(-90 * time.Second), now.Add(-90 * time.Second)},
+		{"4102|COMPLETED|cpu|2|00:05:00", now.Add(-10 * time.Second), now.Add(20 * time.Second)},
+	}
+	clock := now
+	runner := runCommand
+	t.Cleanup(func() { runCommand = runner })
+	runCommand = func(ctx context.Context, path string, args ...string) ([]byte, error) {
+		var start, end time.Time
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "--starttime=") {
+				start, _ = time.ParseInLocation(sacctTimeLayout, strings.TrimPrefix(arg, "--starttime="), time.Local)
+			}
+			if strings.HasPrefix(arg, "--endtime=") {
+				end, _ = time.ParseInLocation(sacctTimeLayout, strings.TrimPrefix(arg, "--endtime="), time.Local)
+			}
+		}
+		var lines []string
+		for _, r := range records {
+			if !r.recorded.After(clock) && !r.end.Before(start) && !r.end.After(end) {
+				lines = append(lines, r.line)
+			}
+		}
+		return []byte(strings.Join(lines, "\n")), nil
+	}
+
+	ac := NewAccountingCollector()
+	ac.window = 5 * time.Minute
+	ac.lag = time.Minute
+	ac.update(clock)
+	assert.Equal(t, 1.0, ac.totals.jobs["cpu"]["completed"])
+
+	// The late record is still counted by the query covering its end time
+	for i := 0; i < 3; i++ {
+		clock = clock.Add(30 * time.Second)
+		ac.update(clock)
+	}
+	assert.Equal(t, 2.0, ac.totals.jobs["cpu"]["completed"])
+	assert.Equal(t, 4*600.0+2*300.0, ac.totals.cpuSeconds["cpu"])
+}
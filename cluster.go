@@ -0,0 +1,58 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/prometheus/common/log"
+)
+
+var (
+	clusterNameOnce sync.Once
+	clusterName     string
+)
+
+// ClusterData executes scontrol to read the configuration of the Slurm controller
+func ClusterData() ([]byte, error) {
+	return RunCommand("cluster", "scontrol", "show", "config")
+}
+
+// ParseClusterName extracts the ClusterName from the output of scontrol show config
+func ParseClusterName(input []byte) string {
+	for _, line := range strings.Split(string(input), "\n") {
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) == 2 && strings.TrimSpace(kv[0]) == "ClusterName" {
+			return strings.TrimSpace(kv[1])
+		}
+	}
+	return ""
+}
+
+// DetectClusterName returns the name of the cluster as configured in Slurm.
+// The configuration is only read once, the name does not change at runtime.
+func DetectClusterName() string {
+	clusterNameOnce.Do(func() {
+		out, err := ClusterData()
+		if err != nil {
+			log.Warnf("Unable to detect the cluster name: %v", err)
+			return
+		}
+		clusterName = ParseClusterName(out)
+	})
+	return clusterName
+}
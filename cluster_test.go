@@ -0,0 +1,46 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseClusterName(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/scontrol_config.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	assert.Equal(t, "hpc-prod", ParseClusterName(data))
+	assert.Equal(t, "", ParseClusterName([]byte("")))
+}
+
+func TestDetectClusterName(t *testing.T) {
+	defer func() { clusterNameOnce, clusterName = sync.Once{}, "" }()
+	clusterNameOnce, clusterName = sync.Once{}, ""
+	calls := stubCommands(t, map[string]string{"scontrol": "test_data/scontrol_config.txt"})
+	assert.Equal(t, "hpc-prod", DetectClusterName())
+	assert.Equal(t, []string{"show", "config"}, calls["scontrol"])
+
+	// A failing scontrol leaves the name unknown
+	clusterNameOnce, clusterName = sync.Once{}, ""
+	stubCommands(t, map[string]string{})
+	assert.Equal(t, "", DetectClusterName())
+}
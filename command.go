@@ -0,0 +1,181 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+// Time of the last successful command of every collector, to alert on a
+// single collector failing while the others are fine
+var collectorLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "slurm_exporter_collector_last_success_timestamp_seconds",
+	Help: "Unix timestamp of the last successful Slurm command of the collector",
+}, []string{"collector"})
+
+// Collectors whose commands failed with a permission error, e.g. sacct or
+// sacctmgr run by a user without access to the accounting
+var collectorPermissionDenied = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "slurm_exporter_collector_permission_denied",
+	Help: "1 if the Slurm commands of the collector are disabled after a permission error",
+}, []string{"collector"})
+
+// ErrPermissionDenied is returned for the commands of a collector which is
+// disabled after a permission error, they are not retried until a restart
+var ErrPermissionDenied = errors.New("permission denied")
+
+var (
+	deniedMutex      sync.Mutex
+	deniedCollectors = make(map[string]bool)
+)
+
+// Messages of the Slurm commands when the user is not allowed to run them
+var permissionDeniedPatterns = []string{
+	"permission denied",
+	"access denied",
+	"not authorized",
+	"not permitted",
+}
+
+// IsPermissionDenied reports whether a failed command was refused for
+// lack of permissions, from the error itself or the stderr of the command
+func IsPermissionDenied(err error, stderr []byte) bool {
+	if errors.Is(err, os.ErrPermission) {
+		return true
+	}
+	message := strings.ToLower(string(stderr))
+	for _, pattern := range permissionDeniedPatterns {
+		if strings.Contains(message, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectorDenied reports whether a collector is disabled after a permission error
+func collectorDenied(collector string) bool {
+	deniedMutex.Lock()
+	defer deniedMutex.Unlock()
+	return deniedCollectors[collector]
+}
+
+// markDenied disables a collector after a permission error, of a Slurm
+// command or of slurmrestd, until the exporter is restarted
+func markDenied(collector string) {
+	deniedMutex.Lock()
+	deniedCollectors[collector] = true
+	deniedMutex.Unlock()
+	collectorPermissionDenied.WithLabelValues(collector).Set(1)
+}
+
+// CommandPath returns the binary of a Slurm command, its -slurm.<command>-path
+// if set, e.g. /opt/slurm/bin/sinfo or a wrapper script, or else the command
+// itself looked up in the PATH
+func CommandPath(command string) string {
+	if path, ok := commandPaths[command]; ok && *path != "" {
+		return *path
+	}
+	return command
+}
+
+// CollectorTimeout returns the timeout of the commands of a collector,
+// its own -collector.<name>.timeout if set or else -slurm.timeout
+func CollectorTimeout(collector string) time.Duration {
+	if timeout, ok := collectorTimeouts[collector]; ok && *timeout > 0 {
+		return *timeout
+	}
+	return *slurmTimeout
+}
+
+// CommandRunner executes a command until the context is done. It returns the
+// output of the command, on failure an *exec.ExitError with its stderr.
+type CommandRunner func(ctx context.Context, path string, args ...string) ([]byte, error)
+
+// ExecCommand runs the command as a child process of the exporter
+func ExecCommand(ctx context.Context, path string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, path, args...)
+	// Children of a killed command, e.g. of a wrapper script, may keep its
+	// output open, do not wait for them after the timeout
+	cmd.WaitDelay = time.Second
+	return cmd.Output()
+}
+
+// runCommand executes all Slurm commands, tests replace it to serve the
+// output of a command from the test data without a cluster
+var runCommand CommandRunner = ExecCommand
+
+// RunCommand executes a Slurm command on behalf of a collector and returns its
+// output. The last success timestamp of the collector is only updated if the
+// command succeeds, on failure the error includes the stderr of the command.
+// After a permission error the collector is disabled and ErrPermissionDenied
+// returned without executing anything.
+func RunCommand(collector string, command string, args ...string) ([]byte, error) {
+	if collectorDenied(collector) {
+		return nil, ErrPermissionDenied
+	}
+	ctx := context.Background()
+	if timeout := CollectorTimeout(collector); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	out, err := runCommand(ctx, CommandPath(command), args...)
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("%s: timed out after %s", command, CollectorTimeout(collector))
+	}
+	if err != nil {
+		var stderr []byte
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			stderr = exitErr.Stderr
+		}
+		if IsPermissionDenied(err, stderr) {
+			markDenied(collector)
+			err = fmt.Errorf("%w, collector disabled: %v", ErrPermissionDenied, err)
+		}
+		if len(stderr) > 0 {
+			return nil, fmt.Errorf("%s: %w: %s", command, err, strings.TrimSpace(string(stderr)))
+		}
+		return nil, fmt.Errorf("%s: %w", command, err)
+	}
+	collectorLastSuccess.WithLabelValues(collector).SetToCurrentTime()
+	log.With("collector", collector).With("bytes", len(out)).Debugf("Executed %s", command)
+	return out, nil
+}
+
+// CollectorData executes a Slurm command like RunCommand, a failure is logged
+// and no output returned, so a single failing command does not stop the exporter
+func CollectorData(collector string, command string, args ...string) []byte {
+	out, err := RunCommand(collector, command, args...)
+	if err == ErrPermissionDenied {
+		// Logged once when the collector was disabled
+		return nil
+	}
+	if err != nil {
+		log.Errorf("Collector %s: %v", collector, err)
+		return nil
+	}
+	return out
+}
@@ -0,0 +1,185 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunCommandLastSuccess(t *testing.T) {
+	lastSuccess := func() float64 {
+		return testutil.ToFloat64(collectorLastSuccess.WithLabelValues("test"))
+	}
+
+	_, err := RunCommand("test", "false")
+	assert.Error(t, err)
+	assert.Equal(t, 0.0, lastSuccess())
+
+	out, err := RunCommand("test", "echo", "ok")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok\n", string(out))
+	success := lastSuccess()
+	assert.NotZero(t, success)
+
+	// A failure keeps the time of the last success
+	assert.Nil(t, CollectorData("test", "sh", "-c", "echo denied >&2; exit 1"))
+	assert.Equal(t, success, lastSuccess())
+
+	_, err = RunCommand("test", "sh", "-c", "echo denied >&2; exit 1")
+	assert.Contains(t, err.Error(), "denied")
+}
+
+func TestCollectorTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { *collectorTimeouts["sacct"] = timeout }(*collectorTimeouts["sacct"])
+	*collectorTimeouts["sacct"] = 50 * time.Millisecond
+
+	assert.Equal(t, 50*time.Millisecond, CollectorTimeout("sacct"))
+	assert.Equal(t, *slurmTimeout, CollectorTimeout("node"))
+
+	start := time.Now()
+	_, err := RunCommand("sacct", "sleep", "5")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+	assert.True(t, time.Since(start) < 5*time.Second)
+
+	// The other collectors keep the global timeout
+	_, err = RunCommand("node", "sleep", "0.1")
+	assert.NoError(t, err)
+}
+
+func TestRunCommandPermissionDenied(t *testing.T) {
+	denied := func() float64 {
+		return testutil.ToFloat64(collectorPermissionDenied.WithLabelValues("test_denied"))
+	}
+
+	_, err := RunCommand("test_denied", "sh", "-c", "echo 'sacct: error: Access/permission denied' >&2; exit 1")
+	assert.True(t, errors.Is(err, ErrPermissionDenied))
+	assert.Equal(t, 1.0, denied())
+
+	// Not retried, even though the command would succeed now
+	_, err = RunCommand("test_denied", "echo", "ok")
+	assert.Equal(t, ErrPermissionDenied, err)
+	assert.Nil(t, CollectorData("test_denied", "echo", "ok"))
+
+	// Other failures do not disable a collector
+	_, err = RunCommand("test_other", "sh", "-c", "echo 'Unable to contact slurm controller' >&2; exit 1")
+	assert.False(t, errors.Is(err, ErrPermissionDenied))
+	_, err = RunCommand("test_other", "echo", "ok")
+	assert.NoError(t, err)
+}
+
+func TestRESTClientCollectorState(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	client := NewRESTClient("test_rest", server.URL, "expired", "v0.0.40")
+
+	_, err := client.Get("nodes")
+	assert.NoError(t, err)
+	assert.NotZero(t, testutil.ToFloat64(collectorLastSuccess.WithLabelValues("test_rest")))
+
+	// A rejected token disables the collector like a denied command
+	status = http.StatusUnauthorized
+	_, err = client.Get("nodes")
+	assert.True(t, errors.Is(err, ErrPermissionDenied))
+	assert.Equal(t, 1.0, testutil.ToFloat64(collectorPermissionDenied.WithLabelValues("test_rest")))
+
+	status = http.StatusOK
+	_, err = client.Get("nodes")
+	assert.Equal(t, ErrPermissionDenied, err)
+
+	// Other failures do not disable a collector
+	status = http.StatusInternalServerError
+	other := NewRESTClient("test_rest_other", server.URL, "", "v0.0.40")
+	_, err = other.Get("nodes")
+	assert.False(t, errors.Is(err, ErrPermissionDenied))
+	assert.Zero(t, testutil.ToFloat64(collectorLastSuccess.WithLabelValues("test_rest_other")))
+}
+
+func TestNodeDataTimeout(t *testing.T) {
+	// A hanging sinfo first in the PATH
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "sinfo"), []byte("#!/bin/sh\nsleep 5\n"), 0755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	defer func(timeout time.Duration) { *collectorTimeouts["node"] = timeout }(*collectorTimeouts["node"])
+	*collectorTimeouts["node"] = 50 * time.Millisecond
+
+	start := time.Now()
+	_, err := NodeData()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+func TestCommandPath(t *testing.T) {
+	assert.Equal(t, "sinfo", CommandPath("sinfo"))
+
+	// A wrapper with a non-standard name outside of the PATH
+	dir := t.TempDir()
+	wrapper := filepath.Join(dir, "sinfo-wrapper")
+	assert.NoError(t, os.WriteFile(wrapper, []byte("#!/bin/sh\necho wrapped \"$@\"\n"), 0755))
+	defer func(path string) { *commandPaths["sinfo"] = path }(*commandPaths["sinfo"])
+	*commandPaths["sinfo"] = wrapper
+
+	assert.Equal(t, wrapper, CommandPath("sinfo"))
+	out, err := RunCommand("node", "sinfo", "-h")
+	assert.NoError(t, err)
+	assert.Equal(t, "wrapped -h\n", string(out))
+}
+
+// stubCommands replaces the execution of the Slurm commands for a test, every
+// command prints its test data file and the arguments of the calls are recorded
+func stubCommands(t *testing.T, files map[string]string) map[string][]string {
+	calls := make(map[string][]string)
+	runner := runCommand
+	t.Cleanup(func() { runCommand = runner })
+	runCommand = func(ctx context.Context, path string, args ...string) ([]byte, error) {
+		calls[path] = args
+		file, ok := files[path]
+		if !ok {
+			return nil, fmt.Errorf("%s: not stubbed", path)
+		}
+		return os.ReadFile(file)
+	}
+	return calls
+}
+
+func TestRunCommandStub(t *testing.T) {
+	calls := stubCommands(t, map[string]string{"sinfo": "test_data/sinfo_mem.txt"})
+
+	out, err := RunCommand("test_stub", "sinfo", "-h")
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "a048")
+	assert.Equal(t, []string{"-h"}, calls["sinfo"])
+	assert.NotZero(t, testutil.ToFloat64(collectorLastSuccess.WithLabelValues("test_stub")))
+
+	_, err = RunCommand("test_stub", "squeue")
+	assert.Error(t, err)
+}
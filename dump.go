@@ -0,0 +1,87 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Metric family of the JSON dump
+type DumpFamily struct {
+	Name    string       `json:"name"`
+	Help    string       `json:"help"`
+	Type    string       `json:"type"`
+	Metrics []DumpMetric `json:"metrics"`
+}
+
+// Single series of the JSON dump, histograms and summaries only carry their count and sum
+type DumpMetric struct {
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+	Count  uint64            `json:"count,omitempty"`
+}
+
+// DumpFamilies converts the gathered metric families into their JSON form
+func DumpFamilies(families []*dto.MetricFamily) []DumpFamily {
+	dump := make([]DumpFamily, 0, len(families))
+	for _, family := range families {
+		df := DumpFamily{
+			Name:    family.GetName(),
+			Help:    family.GetHelp(),
+			Type:    strings.ToLower(family.GetType().String()),
+			Metrics: make([]DumpMetric, 0, len(family.GetMetric())),
+		}
+		for _, metric := range family.GetMetric() {
+			dm := DumpMetric{Labels: make(map[string]string)}
+			for _, label := range metric.GetLabel() {
+				dm.Labels[label.GetName()] = label.GetValue()
+			}
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				dm.Value = metric.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				dm.Value = metric.GetGauge().GetValue()
+			case dto.MetricType_SUMMARY:
+				dm.Value = metric.GetSummary().GetSampleSum()
+				dm.Count = metric.GetSummary().GetSampleCount()
+			case dto.MetricType_HISTOGRAM:
+				dm.Value = metric.GetHistogram().GetSampleSum()
+				dm.Count = metric.GetHistogram().GetSampleCount()
+			default:
+				dm.Value = metric.GetUntyped().GetValue()
+			}
+			df.Metrics = append(df.Metrics, dm)
+		}
+		dump = append(dump, df)
+	}
+	return dump
+}
+
+// DumpJSON runs all collectors of the gatherer once and writes their metrics as JSON
+func DumpJSON(gatherer prometheus.Gatherer, w io.Writer) error {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(DumpFamilies(families))
+}
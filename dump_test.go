@@ -0,0 +1,58 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDumpJSON(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	alloc := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "slurm_node_cpu_alloc", Help: "Allocated CPUs per node"}, []string{"node"})
+	alloc.WithLabelValues("a048").Set(3)
+	alloc.WithLabelValues("a049").Set(16)
+	scrapes := prometheus.NewCounter(prometheus.CounterOpts{Name: "slurm_exporter_scrapes_total", Help: "Total number of scrapes"})
+	scrapes.Add(2)
+	registry.MustRegister(alloc, scrapes)
+
+	var out bytes.Buffer
+	assert.NoError(t, DumpJSON(registry, &out))
+
+	var dump []DumpFamily
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &dump))
+	assert.Equal(t, []DumpFamily{
+		{
+			Name:    "slurm_exporter_scrapes_total",
+			Help:    "Total number of scrapes",
+			Type:    "counter",
+			Metrics: []DumpMetric{{Labels: map[string]string{}, Value: 2}},
+		},
+		{
+			Name: "slurm_node_cpu_alloc",
+			Help: "Allocated CPUs per node",
+			Type: "gauge",
+			Metrics: []DumpMetric{
+				{Labels: map[string]string{"node": "a048"}, Value: 3},
+				{Labels: map[string]string{"node": "a049"}, Value: 16},
+			},
+		},
+	}, dump)
+}
@@ -0,0 +1,88 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ParseNodeEnergy takes the details of the nodes from scontrol
+// It returns the energy consumed by every node in joules, from the
+// ConsumedJoules field reported by the acct_gather_energy plugin.
+// Nodes without energy data, e.g. "ConsumedJoules=n/s", are omitted
+func ParseNodeEnergy(scontrolNodes map[string]map[string]string) map[string]float64 {
+	energy := make(map[string]float64)
+	for node, record := range scontrolNodes {
+		joules, err := strconv.ParseFloat(record["ConsumedJoules"], 64)
+		if err != nil {
+			continue
+		}
+		energy[node] = joules
+	}
+	return energy
+}
+
+// ParseNodePower takes the details of the nodes from scontrol
+// It returns the current power draw of every node in watts, from the
+// CurrentWatts field. Nodes without energy data ("n/s" or "N/A") are omitted
+func ParseNodePower(scontrolNodes map[string]map[string]string) map[string]float64 {
+	power := make(map[string]float64)
+	for node, record := range scontrolNodes {
+		watts, err := strconv.ParseFloat(record["CurrentWatts"], 64)
+		if err != nil {
+			continue
+		}
+		power[node] = watts
+	}
+	return power
+}
+
+/*
+ * Implement the Prometheus Collector interface and feed the
+ * energy consumption of the nodes into it.
+ * https://godoc.org/github.com/prometheus/client_golang/prometheus#Collector
+ */
+
+func NewEnergyCollector() *EnergyCollector {
+	return &EnergyCollector{
+		energyTotal: prometheus.NewDesc("slurm_node_energy_joules_total", "Energy consumed by the node in joules", []string{"node"}, nil),
+		power:       prometheus.NewDesc("slurm_node_power_watts", "Current power draw of the node in watts", []string{"node"}, nil),
+	}
+}
+
+type EnergyCollector struct {
+	energyTotal *prometheus.Desc
+	power       *prometheus.Desc
+}
+
+// Send all metric descriptions
+func (ec *EnergyCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ec.energyTotal
+	ch <- ec.power
+}
+
+func (ec *EnergyCollector) Collect(ch chan<- prometheus.Metric) {
+	nodes := ParseScontrolNodes(ScontrolNodesData("energy"))
+	for node, joules := range ParseNodeEnergy(nodes) {
+		// Reset when slurmd restarts, which rate() handles for counters
+		ch <- prometheus.MustNewConstMetric(ec.energyTotal, prometheus.CounterValue, joules, node)
+	}
+	for node, watts := range ParseNodePower(nodes) {
+		ch <- prometheus.MustNewConstMetric(ec.power, prometheus.GaugeValue, watts, node)
+	}
+}
@@ -0,0 +1,44 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeEnergy(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/scontrol_energy.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	energy := ParseNodeEnergy(ParseScontrolNodes(data))
+
+	assert.Equal(t, map[string]float64{"node01": 8541230, "node02": 0}, energy)
+}
+
+func TestNodePower(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/scontrol_energy.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	power := ParseNodePower(ParseScontrolNodes(data))
+
+	// node03 without energy accounting has no series
+	assert.Equal(t, map[string]float64{"node01": 412, "node02": 0, "node04": 0}, power)
+}
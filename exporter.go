@@ -0,0 +1,161 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/prometheus/common/log"
+)
+
+/*
+ * Metrics about the exporter itself, as opposed to the metrics
+ * extracted from the Slurm commands.
+ */
+
+// Number of times the metrics endpoint has been scraped
+var scrapesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "slurm_exporter_scrapes_total",
+	Help: "Total number of scrapes of the metrics endpoint",
+})
+
+// Timestamp of the last heartbeat, a dead man's switch which keeps
+// moving as long as the exporter is alive, regardless of the Slurm data
+var heartbeat = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "slurm_exporter_heartbeat",
+	Help: "Unix timestamp of the last heartbeat of the exporter",
+})
+
+// HeartbeatLoop sets the heartbeat to the current time on every interval, it never returns
+func HeartbeatLoop(interval time.Duration) {
+	for {
+		heartbeat.SetToCurrentTime()
+		time.Sleep(interval)
+	}
+}
+
+// InstrumentHandler wraps the metrics handler and counts every scrape,
+// so the load on the Slurm controller can be correlated with the scrape frequency
+func InstrumentHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scrapesTotal.Inc()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// MetricsHandler returns the handler of the metrics endpoint. The payload is
+// gzip compressed whenever the client accepts it, forceGzip compresses it
+// regardless of the Accept-Encoding header of the request. The metrics of the
+// handler itself are registered with registerer, like those of the collectors.
+func MetricsHandler(registerer prometheus.Registerer, gatherer prometheus.Gatherer, forceGzip bool) http.Handler {
+	handler := InstrumentHandler(promhttp.InstrumentMetricHandler(
+		registerer, promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})))
+	if !forceGzip {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set("Accept-Encoding", "gzip")
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// LandingPage returns the handler of "/", a page linking to the metrics
+// endpoint. Other paths are not found.
+func LandingPage(metricsPath string) http.Handler {
+	page := []byte(`<html>
+<head><title>Slurm Exporter</title></head>
+<body>
+<h1>Slurm Exporter</h1>
+<p><a href="` + html.EscapeString(metricsPath) + `">Metrics</a></p>
+</body>
+</html>
+`)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	})
+}
+
+// TLSConfig returns the TLS configuration of the metrics endpoint, requiring
+// the clients to present a certificate signed by one of the CAs in the
+// clientCAFile. Without a file any client is accepted and nil is returned.
+func TLSConfig(clientCAFile string) (*tls.Config, error) {
+	if clientCAFile == "" {
+		return nil, nil
+	}
+	pem, err := ioutil.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", clientCAFile)
+	}
+	return &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+	}, nil
+}
+
+// ListenAndServe serves the handler on addr, with HTTPS if a certificate and
+// its key are given and plain HTTP otherwise
+func ListenAndServe(addr, certFile, keyFile, clientCAFile string, handler http.Handler) error {
+	if certFile == "" && keyFile == "" {
+		if clientCAFile != "" {
+			return fmt.Errorf("a client CA requires a TLS certificate and key")
+		}
+		return http.ListenAndServe(addr, handler)
+	}
+	if certFile == "" || keyFile == "" {
+		return fmt.Errorf("both a TLS certificate and its key are required")
+	}
+	config, err := TLSConfig(clientCAFile)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Addr: addr, Handler: handler, TLSConfig: config}
+	return server.ListenAndServeTLS(certFile, keyFile)
+}
+
+// PushMetrics collects all metrics once and pushes them to a Pushgateway,
+// replacing the metrics previously pushed for the same job
+func PushMetrics(url string, job string, gatherer prometheus.Gatherer) error {
+	scrapesTotal.Inc()
+	return push.New(url, job).Gatherer(gatherer).Push()
+}
+
+// PushLoop pushes the metrics to the Pushgateway on every interval, it never returns
+func PushLoop(url string, job string, gatherer prometheus.Gatherer, interval time.Duration) {
+	for {
+		if err := PushMetrics(url, job, gatherer); err != nil {
+			log.Errorf("Unable to push metrics to %s: %v", url, err)
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,153 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"compress/gzip"
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrapesTotal(t *testing.T) {
+	handler := InstrumentHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	before := testutil.ToFloat64(scrapesTotal)
+	for i := 0; i < 3; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+	}
+	assert.Equal(t, before+3, testutil.ToFloat64(scrapesTotal))
+}
+
+func TestMetricsHandlerGzip(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_gauge", Help: "Test gauge"}))
+
+	// Compression negotiated by the client
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	MetricsHandler(registry, registry, false).ServeHTTP(rec, req)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+
+	// No compression unless requested
+	rec = httptest.NewRecorder()
+	MetricsHandler(registry, registry, false).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, "", rec.Header().Get("Content-Encoding"))
+
+	// Compression forced by the command line
+	rec = httptest.NewRecorder()
+	MetricsHandler(registry, registry, true).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	_, err := gzip.NewReader(rec.Body)
+	assert.NoError(t, err)
+}
+
+func TestPushMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_gauge", Help: "Test gauge"})
+	gauge.Set(42)
+	registry.MustRegister(gauge)
+
+	var method, path string
+	var body []byte
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer gateway.Close()
+
+	assert.NoError(t, PushMetrics(gateway.URL, "slurm_exporter", registry))
+	assert.Equal(t, "PUT", method)
+	assert.Equal(t, "/metrics/job/slurm_exporter", path)
+	assert.Contains(t, string(body), "test_gauge")
+
+	gateway.Close()
+	assert.Error(t, PushMetrics(gateway.URL, "slurm_exporter", registry))
+}
+
+func TestHeartbeat(t *testing.T) {
+	go HeartbeatLoop(10 * time.Millisecond)
+	assert.Eventually(t, func() bool { return testutil.ToFloat64(heartbeat) > 0 }, time.Second, 5*time.Millisecond)
+	first := testutil.ToFloat64(heartbeat)
+	assert.Eventually(t, func() bool { return testutil.ToFloat64(heartbeat) > first }, time.Second, 5*time.Millisecond)
+}
+
+func TestTLSConfig(t *testing.T) {
+	config, err := TLSConfig("")
+	assert.NoError(t, err)
+	assert.Nil(t, config)
+
+	_, err = TLSConfig("test_data/missing_ca.pem")
+	assert.Error(t, err)
+
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.pem")
+	assert.NoError(t, ioutil.WriteFile(invalid, []byte("not a certificate"), 0600))
+	_, err = TLSConfig(invalid)
+	assert.Error(t, err)
+
+	// Any certificate works as a CA for the pool
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	ca := filepath.Join(dir, "ca.pem")
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}
+	assert.NoError(t, ioutil.WriteFile(ca, pem.EncodeToMemory(block), 0600))
+	config, err = TLSConfig(ca)
+	assert.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, config.ClientAuth)
+}
+
+func TestListenAndServeTLSFlags(t *testing.T) {
+	// Half a key pair or a client CA without HTTPS is refused before listening
+	assert.Error(t, ListenAndServe("127.0.0.1:0", "cert.pem", "", "", nil))
+	assert.Error(t, ListenAndServe("127.0.0.1:0", "", "key.pem", "", nil))
+	assert.Error(t, ListenAndServe("127.0.0.1:0", "", "", "ca.pem", nil))
+}
+
+func TestLandingPage(t *testing.T) {
+	handler := LandingPage("/slurm/metrics")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `<a href="/slurm/metrics">`)
+
+	// Typos of the metrics path are not answered with the landing page
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/metric", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestMetricsHandlerRegisterer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registerer := prometheus.WrapRegistererWith(prometheus.Labels{"cluster": "test"}, registry)
+	rec := httptest.NewRecorder()
+	MetricsHandler(registerer, registry, false).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	// The handler metrics carry the labels of the registerer
+	rec = httptest.NewRecorder()
+	MetricsHandler(prometheus.NewRegistry(), registry, false).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), `promhttp_metric_handler_requests_total{cluster="test",code="200"} 1`)
+}
@@ -0,0 +1,132 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+// Execute the scontrol command to get the details of the jobs, with -d
+// every job lists the GRES allocated on each of its nodes, e.g.
+// "Nodes=gpu01 CPU_IDs=0-7 Mem=64000 GRES=gpu:a100:2(IDX:0-1)"
+func JobGPUsData() []byte {
+	return CollectorData("gpu_stranded", "scontrol", "show", "job", "-d")
+}
+
+// Job states holding the GRES of their nodes
+var gpuHoldingJobStates = map[string]bool{
+	"RUNNING":    true,
+	"SUSPENDED":  true,
+	"COMPLETING": true,
+}
+
+// ParseJobGPUs takes the output of scontrol show job -d
+// It returns per node the GPU indices claimed by the jobs holding them
+func ParseJobGPUs(input []byte) map[string]map[int]bool {
+	claimed := make(map[string]map[int]bool)
+	holding := false
+	for _, line := range strings.Split(string(input), "\n") {
+		record := ParseScontrolRecord(line)
+		if _, ok := record["JobId"]; ok {
+			// A new job, its state follows on one of the next lines
+			holding = false
+		}
+		if state, ok := record["JobState"]; ok {
+			holding = gpuHoldingJobStates[state]
+		}
+		nodes, ok := record["Nodes"]
+		if !ok || !holding {
+			continue
+		}
+		// Older Slurm versions name the field GRES_IDX
+		gres, ok := record["GRES"]
+		if !ok {
+			gres = record["GRES_IDX"]
+		}
+		for _, resource := range ParseGres(gres) {
+			if resource.name != "gpu" {
+				continue
+			}
+			for _, node := range ExpandHostlist(nodes) {
+				if claimed[node] == nil {
+					claimed[node] = make(map[int]bool)
+				}
+				for _, i := range ParseGresIndex(resource.index) {
+					claimed[node][i] = true
+				}
+			}
+		}
+	}
+	return claimed
+}
+
+// StrandedGPUs returns per node the GPU indices allocated according to the
+// node but not claimed by any job, e.g. left over by a crashed job
+func StrandedGPUs(nodes map[string]*NodeMetrics, claimed map[string]map[int]bool) map[string][]int {
+	stranded := make(map[string][]int)
+	for name, node := range nodes {
+		for _, entry := range node.gpus {
+			for i, used := range entry.index {
+				if used == 1 && !claimed[name][entry.first+i] {
+					stranded[name] = append(stranded[name], entry.first+i)
+				}
+			}
+		}
+		sort.Ints(stranded[name])
+	}
+	return stranded
+}
+
+/*
+ * Implement the Prometheus Collector interface and feed the
+ * Slurm stranded GPU metrics into it.
+ * https://godoc.org/github.com/prometheus/client_golang/prometheus#Collector
+ */
+
+func NewStrandedGPUsCollector() *StrandedGPUsCollector {
+	return &StrandedGPUsCollector{
+		stranded: prometheus.NewDesc("slurm_node_gpu_stranded", "GPU allocated on the node but not claimed by any job", []string{"node", "index"}, nil),
+	}
+}
+
+type StrandedGPUsCollector struct {
+	stranded *prometheus.Desc
+}
+
+// Send all metric descriptions
+func (sc *StrandedGPUsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sc.stranded
+}
+
+func (sc *StrandedGPUsCollector) Collect(ch chan<- prometheus.Metric) {
+	// The nodes are read first, a job starting in between is then
+	// listed without its GPUs being seen as allocated yet
+	nodes, err := NodeGetMetrics()
+	if err != nil {
+		log.Errorf("Stranded GPUs: %v", err)
+		return
+	}
+	for node, indices := range StrandedGPUs(nodes, ParseJobGPUs(JobGPUsData())) {
+		for _, i := range indices {
+			ch <- prometheus.MustNewConstMetric(sc.stranded, prometheus.GaugeValue, 1, node, strconv.Itoa(i))
+		}
+	}
+}
@@ -0,0 +1,55 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJobGPUs(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/scontrol_jobs_detail.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	claimed := ParseJobGPUs(data)
+
+	// The completed job 4002 does not hold its GPU anymore
+	assert.Equal(t, map[string]map[int]bool{
+		"gpu01": {0: true, 1: true},
+		"gpu02": {3: true},
+		"gpu03": {0: true},
+	}, claimed)
+}
+
+func TestStrandedGPUs(t *testing.T) {
+	jobs, err := ioutil.ReadFile("test_data/scontrol_jobs_detail.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	data, err := ioutil.ReadFile("test_data/sinfo_stranded.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	stranded := StrandedGPUs(ParseNodeMetrics(data), ParseJobGPUs(jobs))
+
+	// GPU 2 of gpu01 is still allocated after job 4002 ended
+	assert.Equal(t, []int{2}, stranded["gpu01"])
+	assert.Empty(t, stranded["gpu02"])
+	assert.Empty(t, stranded["gpu03"])
+}
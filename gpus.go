@@ -17,9 +17,6 @@ package main
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/common/log"
-	"io/ioutil"
-	"os/exec"
 	"strings"
 	"strconv"
 )
@@ -40,7 +37,7 @@ func ParseAllocatedGPUs() map[string]float64 {
 	gpu_map := make(map[string]float64)
 
 	args := []string{"-a", "-X", "--format=AllocTRES", "--state=RUNNING", "--noheader", "--parsable2"}
-	output := string(Execute("sacct", args))
+	output := string(CollectorData("gpus", "sacct", args...))
 
 	if len(output) == 0 {
 		return make(map[string]float64)
@@ -72,7 +69,7 @@ func ParseTotalGPUs() map[string]float64 {
 	gpu_map := make(map[string]float64)
 
 	args := []string{"-h", "-o \"%n %G\""}
-	output := string(Execute("sinfo", args))
+	output := string(CollectorData("gpus", "sinfo", args...))
 
 	if len(output) == 0 {
 		return make(map[string]float64)
@@ -134,23 +131,6 @@ func ParseGPUsMetrics() map[string]*GPUsMetrics {
 	return types
 }
 
-// Execute the sinfo command and return its output
-func Execute(command string, arguments []string) []byte {
-	cmd := exec.Command(command, arguments...)
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		log.Fatal(err)
-	}
-	if err := cmd.Start(); err != nil {
-		log.Fatal(err)
-	}
-	out, _ := ioutil.ReadAll(stdout)
-	if err := cmd.Wait(); err != nil {
-		log.Fatal(err)
-	}
-	return out
-}
-
 /*
  * Implement the Prometheus Collector interface and feed the
  * Slurm scheduler metrics into it.
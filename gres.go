@@ -0,0 +1,89 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Gres is a single generic resource of a node, e.g. "gpu:a100:6(IDX:0,2-6)"
+type Gres struct {
+	name  string // gpu, mps, ...
+	gtype string // a100, empty if the resource has no type
+	count uint64
+	index string // 0,2-6 from the IDX detail, empty if not given
+}
+
+// ParseGres splits a GRES list such as "gpu:a100:4(S:0-1),mps:a100:400"
+// into its resources, commas within parentheses do not separate resources
+func ParseGres(list string) []Gres {
+	var resources []Gres
+	depth, start := 0, 0
+	list = strings.TrimSpace(list)
+	for i := 0; i <= len(list); i++ {
+		if i < len(list) {
+			switch list[i] {
+			case '(':
+				depth++
+				continue
+			case ')':
+				depth--
+				continue
+			case ',':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		if token := list[start:i]; token != "" && token != "(null)" {
+			resources = append(resources, parseGresToken(token))
+		}
+		start = i + 1
+	}
+	return resources
+}
+
+// parseGresToken parses name[:type][:count][(details)]
+func parseGresToken(token string) Gres {
+	var gres Gres
+	if open := strings.Index(token, "("); open >= 0 {
+		// The index is the last detail, e.g. "(IDX:0,2-6)" or "(S:0-1,IDX:0)"
+		details := strings.TrimSuffix(token[open+1:], ")")
+		if i := strings.Index(details, "IDX:"); i >= 0 {
+			gres.index = details[i+len("IDX:"):]
+		}
+		token = token[:open]
+	}
+	parts := strings.Split(token, ":")
+	gres.name = parts[0]
+	gres.count = 1
+	switch len(parts) {
+	case 2:
+		// Either name:count or name:type with an implicit count of one
+		if count, err := strconv.ParseUint(parts[1], 10, 64); err == nil {
+			gres.count = count
+		} else {
+			gres.gtype = parts[1]
+		}
+	case 3:
+		gres.gtype = parts[1]
+		gres.count, _ = strconv.ParseUint(parts[2], 10, 64)
+	}
+	return gres
+}
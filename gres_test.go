@@ -0,0 +1,35 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGres(t *testing.T) {
+	assert.Equal(t, []Gres{
+		{name: "gpu", gtype: "a100", count: 6, index: "0,2-6"},
+		{name: "mps", gtype: "a100", count: 50, index: "0"},
+	}, ParseGres("gpu:a100:6(IDX:0,2-6),mps:a100:50(IDX:0)"))
+	assert.Equal(t, []Gres{
+		{name: "gpu", gtype: "a100", count: 2},
+		{name: "mps", count: 200},
+	}, ParseGres("gpu:a100:2(S:0-1),mps:200"))
+	assert.Equal(t, []Gres{{name: "gpu", gtype: "k80", count: 1}}, ParseGres("gpu:k80"))
+	assert.Empty(t, ParseGres("(null)"))
+}
@@ -0,0 +1,93 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ExpandHostlist expands a Slurm hostlist expression, e.g.
+// "node[01-03,07],gpu01" into node01, node02, node03, node07 and gpu01.
+// Ranges keep the zero padding of their lower bound.
+func ExpandHostlist(hostlist string) []string {
+	var hosts []string
+	for _, expr := range splitHostlist(hostlist) {
+		hosts = append(hosts, expandHostExpr(expr)...)
+	}
+	return hosts
+}
+
+// splitHostlist splits a hostlist at the commas outside of brackets
+func splitHostlist(hostlist string) []string {
+	var exprs []string
+	depth, start := 0, 0
+	for i, c := range hostlist {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				exprs = append(exprs, hostlist[start:i])
+				start = i + 1
+			}
+		}
+	}
+	exprs = append(exprs, hostlist[start:])
+	var result []string
+	for _, expr := range exprs {
+		if expr = strings.TrimSpace(expr); expr != "" {
+			result = append(result, expr)
+		}
+	}
+	return result
+}
+
+// expandHostExpr expands a single expression, which may contain several bracket groups
+func expandHostExpr(expr string) []string {
+	open := strings.Index(expr, "[")
+	if open < 0 {
+		return []string{expr}
+	}
+	length := strings.Index(expr[open:], "]")
+	if length < 0 {
+		return []string{expr}
+	}
+	prefix, ranges, suffixes := expr[:open], expr[open+1:open+length], expandHostExpr(expr[open+length+1:])
+	var hosts []string
+	for _, r := range strings.Split(ranges, ",") {
+		bounds := strings.SplitN(r, "-", 2)
+		low, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			continue
+		}
+		high := low
+		if len(bounds) == 2 {
+			if high, err = strconv.Atoi(bounds[1]); err != nil {
+				continue
+			}
+		}
+		for i := low; i <= high; i++ {
+			for _, suffix := range suffixes {
+				hosts = append(hosts, fmt.Sprintf("%s%0*d%s", prefix, len(bounds[0]), i, suffix))
+			}
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,30 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandHostlist(t *testing.T) {
+	assert.Equal(t, []string{"gpu01"}, ExpandHostlist("gpu01"))
+	assert.Equal(t, []string{"node01", "node02", "node03", "node07", "gpu01"}, ExpandHostlist("node[01-03,07],gpu01"))
+	assert.Equal(t, []string{"s0", "s1"}, ExpandHostlist("s[0-1]"))
+	assert.Equal(t, []string{"r1n1", "r1n2", "r2n1", "r2n2"}, ExpandHostlist("r[1-2]n[1-2]"))
+	assert.Empty(t, ExpandHostlist(""))
+}
@@ -0,0 +1,213 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// JobDetails stores the metrics of a single job from squeue
+type JobDetails struct {
+	state     string
+	partition string
+	user      string
+	cpus      uint64
+	nodes     uint64
+	timeUsed  float64
+	// Why the job is pending, "None" for running jobs
+	reason string
+}
+
+func JobGetMetrics() map[string]*JobDetails {
+	return jobDataSource.Jobs()
+}
+
+// JobDataSource provides the jobs of the job and queue collectors, by
+// executing squeue or by querying the REST API of slurmrestd
+type JobDataSource interface {
+	// Jobs returns the jobs of the job collector, keyed by job id
+	Jobs() map[string]*JobDetails
+	// QueueJobs returns the jobs of the queue collector
+	QueueJobs() []*QueueJob
+}
+
+// ExecJobSource executes squeue
+type ExecJobSource struct{}
+
+func (ExecJobSource) Jobs() map[string]*JobDetails {
+	return ParseJobMetrics(JobData())
+}
+
+func (ExecJobSource) QueueJobs() []*QueueJob {
+	return ParseQueueJobs(QueueData())
+}
+
+// Sources of the job and of the queue collector
+var jobDataSource JobDataSource = ExecJobSource{}
+var queueDataSource JobDataSource = ExecJobSource{}
+
+// ParseSlurmDuration converts a Slurm time like "5:23", "02:03:04" or
+// "1-02:03:04" into seconds, anything else (e.g. "INVALID") is 0
+func ParseSlurmDuration(duration string) float64 {
+	days := 0
+	if parts := strings.SplitN(duration, "-", 2); len(parts) == 2 {
+		d, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return 0
+		}
+		days, duration = d, parts[1]
+	}
+	seconds := 0
+	for _, part := range strings.Split(duration, ":") {
+		value, err := strconv.Atoi(part)
+		if err != nil {
+			return 0
+		}
+		seconds = seconds*60 + value
+	}
+	// Days with hours only, e.g. "1-02" is one day and two hours
+	if !strings.Contains(duration, ":") {
+		seconds *= 3600
+	}
+	return float64(days*86400 + seconds)
+}
+
+// ParseJobMetrics takes the output of squeue with job details
+// (JobID|State|Partition|UserName|NumCPUs|NumNodes|TimeUsed|Reason)
+// It returns a map of metrics per job id
+func ParseJobMetrics(input []byte) map[string]*JobDetails {
+	jobs := make(map[string]*JobDetails)
+	for _, line := range strings.Split(string(input), "\n") {
+		job := strings.SplitN(strings.TrimRight(line, "\r"), "|", 8)
+		if len(job) < 8 {
+			continue
+		}
+		cpus, _ := strconv.ParseUint(job[4], 10, 64)
+		nodes, _ := strconv.ParseUint(job[5], 10, 64)
+		jobs[job[0]] = &JobDetails{
+			state:     strings.ToLower(job[1]),
+			partition: job[2],
+			user:      job[3],
+			cpus:      cpus,
+			nodes:     nodes,
+			timeUsed:  ParseSlurmDuration(job[6]),
+			// The reason is the last column, it may contain spaces
+			reason: job[7],
+		}
+	}
+	return jobs
+}
+
+// Pending reasons exported on their own, the limits of the QOS and the
+// associations (e.g. QOSMaxJobsPerUserLimit) are kept as well
+var pendingReasons = map[string]bool{
+	"Resources":                   true,
+	"Priority":                    true,
+	"Dependency":                  true,
+	"DependencyNeverSatisfied":    true,
+	"BeginTime":                   true,
+	"JobHeldUser":                 true,
+	"JobHeldAdmin":                true,
+	"ReqNodeNotAvail":             true,
+	"Reservation":                 true,
+	"Licenses":                    true,
+	"PartitionDown":               true,
+	"PartitionInactive":           true,
+	"PartitionNodeLimit":          true,
+	"PartitionTimeLimit":          true,
+	"NodeDown":                    true,
+	"BadConstraints":              true,
+	"launch failed requeued held": true,
+}
+
+var limitReason = regexp.MustCompile(`^(QOS|Assoc)[A-Za-z]*Limit$`)
+
+// PendingReason normalizes the reason of a pending job: the parentheses
+// around it and the details after a comma, e.g. the nodes of
+// "ReqNodeNotAvail, UnavailableNodes:cpu01", are dropped. Reasons
+// not exported on their own are Other.
+func PendingReason(reason string) string {
+	reason = strings.TrimSpace(strings.Trim(strings.TrimSpace(reason), "()"))
+	reason = strings.TrimSpace(strings.SplitN(reason, ",", 2)[0])
+	if pendingReasons[reason] || limitReason.MatchString(reason) {
+		return reason
+	}
+	return "Other"
+}
+
+// PendingJobsByReason counts the pending jobs per normalized reason
+func PendingJobsByReason(jobs map[string]*JobDetails) map[string]float64 {
+	reasons := make(map[string]float64)
+	for _, job := range jobs {
+		if job.state == "pending" {
+			reasons[PendingReason(job.reason)]++
+		}
+	}
+	return reasons
+}
+
+// JobData executes the squeue command to get data for each job
+// It returns the output of the squeue command
+func JobData() []byte {
+	return CollectorData("job", "squeue", "-a", "-h", "-o", "%i|%T|%P|%u|%C|%D|%M|%r")
+}
+
+/*
+ * Implement the Prometheus Collector interface and feed the
+ * Slurm job metrics into it.
+ * https://godoc.org/github.com/prometheus/client_golang/prometheus#Collector
+ */
+
+func NewJobCollector() *JobCollector {
+	labels := []string{"jobid", "state", "partition", "user"}
+	return &JobCollector{
+		cpus:     prometheus.NewDesc("slurm_job_cpus", "CPUs of the job", labels, nil),
+		nodes:    prometheus.NewDesc("slurm_job_nodes", "Nodes of the job", labels, nil),
+		timeUsed: prometheus.NewDesc("slurm_job_time_used_seconds", "Time the job has been running", labels, nil),
+		pending:  prometheus.NewDesc("slurm_jobs_pending", "Pending jobs per reason", []string{"reason"}, nil),
+	}
+}
+
+type JobCollector struct {
+	cpus     *prometheus.Desc
+	nodes    *prometheus.Desc
+	timeUsed *prometheus.Desc
+	pending  *prometheus.Desc
+}
+
+// Send all metric descriptions
+func (jc *JobCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- jc.cpus
+	ch <- jc.nodes
+	ch <- jc.timeUsed
+	ch <- jc.pending
+}
+
+func (jc *JobCollector) Collect(ch chan<- prometheus.Metric) {
+	jobs := JobGetMetrics()
+	for id, job := range jobs {
+		ch <- prometheus.MustNewConstMetric(jc.cpus, prometheus.GaugeValue, float64(job.cpus), id, job.state, job.partition, job.user)
+		ch <- prometheus.MustNewConstMetric(jc.nodes, prometheus.GaugeValue, float64(job.nodes), id, job.state, job.partition, job.user)
+		ch <- prometheus.MustNewConstMetric(jc.timeUsed, prometheus.GaugeValue, job.timeUsed, id, job.state, job.partition, job.user)
+	}
+	for reason, count := range PendingJobsByReason(jobs) {
+		ch <- prometheus.MustNewConstMetric(jc.pending, prometheus.GaugeValue, count, reason)
+	}
+}
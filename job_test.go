@@ -0,0 +1,80 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJobMetrics(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/squeue_jobs.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	jobs := ParseJobMetrics(data)
+
+	assert.Len(t, jobs, 4)
+	assert.Equal(t, &JobDetails{state: "running", partition: "gpu", user: "bob", cpus: 128, nodes: 4, timeUsed: 310, reason: "None"}, jobs["4202"])
+	assert.Equal(t, 93784.0, jobs["4201"].timeUsed)
+	assert.Equal(t, "pending", jobs["4203"].state)
+	assert.Equal(t, "Resources", jobs["4203"].reason)
+
+	// Array jobs and long names are not clipped
+	assert.Equal(t, "gpu-long-running-partition", jobs["123456_[1-1000%50]"].partition)
+	assert.Equal(t, "firstname.lastname.external", jobs["123456_[1-1000%50]"].user)
+	assert.Equal(t, "JobArrayTaskLimit", jobs["123456_[1-1000%50]"].reason)
+}
+
+func TestPendingJobsByReason(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/squeue_pending_reasons.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	jobs := ParseJobMetrics(data)
+	assert.Equal(t, "ReqNodeNotAvail, UnavailableNodes:cpu[01-02]", jobs["5005"].reason)
+
+	// Running jobs are not counted
+	assert.Equal(t, map[string]float64{
+		"Priority":                    2,
+		"Resources":                   1,
+		"Dependency":                  1,
+		"ReqNodeNotAvail":             1,
+		"QOSMaxJobsPerUserLimit":      1,
+		"launch failed requeued held": 1,
+		"JobHeldUser":                 1,
+		"BeginTime":                   1,
+		"Other":                       1,
+	}, PendingJobsByReason(jobs))
+}
+
+func TestPendingReason(t *testing.T) {
+	assert.Equal(t, "Resources", PendingReason("(Resources)"))
+	assert.Equal(t, "ReqNodeNotAvail", PendingReason("(ReqNodeNotAvail, Reserved for maintenance)"))
+	assert.Equal(t, "AssocGrpCpuLimit", PendingReason("AssocGrpCpuLimit"))
+	assert.Equal(t, "Other", PendingReason("QOSNotAllowed"))
+	assert.Equal(t, "Other", PendingReason(""))
+}
+
+func TestParseSlurmDuration(t *testing.T) {
+	assert.Equal(t, 0.0, ParseSlurmDuration("0:00"))
+	assert.Equal(t, 323.0, ParseSlurmDuration("5:23"))
+	assert.Equal(t, 7384.0, ParseSlurmDuration("02:03:04"))
+	assert.Equal(t, 93600.0, ParseSlurmDuration("1-02"))
+	assert.Equal(t, 0.0, ParseSlurmDuration("INVALID"))
+}
@@ -0,0 +1,95 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// LicensesData executes scontrol to get the licenses, one line per license
+func LicensesData() []byte {
+	return CollectorData("licenses", "scontrol", "show", "licenses", "-o")
+}
+
+type LicenseMetrics struct {
+	total float64
+	used  float64
+	free  float64
+}
+
+// ParseLicenses takes the output of scontrol show licenses -o, e.g.
+// "LicenseName=matlab Total=10 Used=3 Free=7 Reserved=0 Remote=no"
+// It returns the metrics of every license, none without configured licenses.
+func ParseLicenses(input []byte) map[string]*LicenseMetrics {
+	licenses := make(map[string]*LicenseMetrics)
+	for _, line := range strings.Split(string(input), "\n") {
+		record := ParseScontrolRecord(line)
+		name, ok := record["LicenseName"]
+		if !ok {
+			continue
+		}
+		lm := &LicenseMetrics{}
+		lm.total, _ = strconv.ParseFloat(record["Total"], 64)
+		lm.used, _ = strconv.ParseFloat(record["Used"], 64)
+		free, err := strconv.ParseFloat(record["Free"], 64)
+		if err != nil {
+			// Not reported by all Slurm versions
+			free = lm.total - lm.used
+		}
+		lm.free = free
+		licenses[name] = lm
+	}
+	return licenses
+}
+
+/*
+ * Implement the Prometheus Collector interface and feed the
+ * usage of the licenses into it.
+ * https://godoc.org/github.com/prometheus/client_golang/prometheus#Collector
+ */
+
+func NewLicensesCollector() *LicensesCollector {
+	labels := []string{"license"}
+	return &LicensesCollector{
+		total: prometheus.NewDesc("slurm_license_total", "Total licenses", labels, nil),
+		used:  prometheus.NewDesc("slurm_license_used", "Licenses used by the jobs", labels, nil),
+		free:  prometheus.NewDesc("slurm_license_free", "Free licenses", labels, nil),
+	}
+}
+
+type LicensesCollector struct {
+	total *prometheus.Desc
+	used  *prometheus.Desc
+	free  *prometheus.Desc
+}
+
+// Send all metric descriptions
+func (lc *LicensesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- lc.total
+	ch <- lc.used
+	ch <- lc.free
+}
+
+func (lc *LicensesCollector) Collect(ch chan<- prometheus.Metric) {
+	for license, lm := range ParseLicenses(LicensesData()) {
+		ch <- prometheus.MustNewConstMetric(lc.total, prometheus.GaugeValue, lm.total, license)
+		ch <- prometheus.MustNewConstMetric(lc.used, prometheus.GaugeValue, lm.used, license)
+		ch <- prometheus.MustNewConstMetric(lc.free, prometheus.GaugeValue, lm.free, license)
+	}
+}
@@ -0,0 +1,40 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLicenses(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/scontrol_licenses.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	licenses := ParseLicenses(data)
+
+	assert.Len(t, licenses, 3)
+	assert.Equal(t, &LicenseMetrics{total: 10, used: 3, free: 7}, licenses["matlab"])
+	assert.Equal(t, 0.0, licenses["ansys@flexlm"].free)
+	// Without Free the free licenses are the unused ones
+	assert.Equal(t, 3.0, licenses["fluent"].free)
+
+	// No licenses configured
+	assert.Empty(t, ParseLicenses([]byte("")))
+}
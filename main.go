@@ -18,46 +18,545 @@ package main
 import (
 	"flag"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/log"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 )
 
-func init() {
-	// Metrics have to be registered to be exposed
-	prometheus.MustRegister(NewAccountsCollector())       // from accounts.go
-	prometheus.MustRegister(NewCPUsCollector())           // from cpus.go
-	prometheus.MustRegister(NewNodesCollector())          // from nodes.go
-	prometheus.MustRegister(NewNodeCollector())           // from node.go
-	prometheus.MustRegister(NewPartitionsCollector())     // from partitions.go
-	prometheus.MustRegister(NewQueueCollector())          // from queue.go
-	prometheus.MustRegister(NewSchedulerCollector())      // from scheduler.go
-	prometheus.MustRegister(NewFairShareCollector())      // from sshare.go
-	prometheus.MustRegister(NewUsersCollector())          // from users.go
-}
-
 var listenAddress = flag.String(
 	"listen-address",
 	":8080",
 	"The address to listen on for HTTP requests.")
 
+var telemetryPath = flag.String(
+	"web.telemetry-path",
+	"/metrics",
+	"Path of the metrics endpoint")
+
+var tlsCert = flag.String(
+	"web.tls-cert",
+	"",
+	"Certificate of the metrics endpoint, serves HTTPS together with -web.tls-key")
+
+var tlsKey = flag.String(
+	"web.tls-key",
+	"",
+	"Private key of the -web.tls-cert certificate")
+
+var tlsClientCA = flag.String(
+	"web.tls-client-ca",
+	"",
+	"CA certificates the clients must present a certificate of, requires -web.tls-cert")
+
 var gpuAcct = flag.Bool(
 	"gpus-acct",
 	false,
 	"Enable GPUs accounting")
 
-func main() {
-	flag.Parse()
+var sacctAcct = flag.Bool(
+	"collector.sacct",
+	false,
+	"Enable the accounting of completed jobs with sacct")
+
+var sacctWindow = flag.Duration(
+	"slurm.sacct-window",
+	5*time.Minute,
+	"Window of the first sacct query of -collector.sacct, later scrapes query the jobs completed since the previous one")
+
+var sacctLag = flag.Duration(
+	"slurm.sacct-lag",
+	time.Minute,
+	"Delay of the sacct queries of -collector.sacct behind the current time, for the records of completed jobs slurmdbd receives late")
+
+var sreportInfo = flag.Bool(
+	"collector.sreport",
+	false,
+	"Enable the utilization of the previous day from sreport")
+
+var sreportInterval = flag.Duration(
+	"collector.sreport.interval",
+	24*time.Hour,
+	"Interval between two runs of sreport, the last result is exported in between")
+
+var topologyInfo = flag.Bool(
+	"collector.topology",
+	false,
+	"Enable the network topology of the switches with scontrol show topology")
+
+var reservationsInfo = flag.Bool(
+	"collector.reservations",
+	false,
+	"Enable the nodes, CPUs and state of the reservations and the running jobs outside of them on the reserved nodes")
+
+var qosLimitsInfo = flag.Bool(
+	"collector.qos_limits",
+	false,
+	"Enable the configured limits of the QOS with sacctmgr show qos")
+
+var nodeReasonsInfo = flag.Bool(
+	"collector.node_reasons",
+	false,
+	"Enable the reasons of the down, drained and failing nodes with sinfo -R")
+
+var licensesInfo = flag.Bool(
+	"collector.licenses",
+	false,
+	"Enable the total, used and free licenses with scontrol show licenses")
+
+var strandedGPUsInfo = flag.Bool(
+	"collector.gpu_stranded",
+	false,
+	"Enable the GPUs allocated on the nodes but not claimed by any job, with scontrol show job -d")
+
+var nodeJobInfo = flag.Bool(
+	"node-job-info",
+	false,
+	"Export the running jobs of every node, high cardinality")
+
+var nodeJobPartition = flag.String(
+	"node-job-info.partition",
+	"",
+	"Limit the running jobs of -node-job-info to a partition")
+
+var energyInfo = flag.Bool(
+	"collector.energy",
+	false,
+	"Enable the energy consumed by every node, requires an acct_gather_energy plugin")
+
+var nodeList = flag.String(
+	"nodes",
+	"",
+	"Limit the node metrics to a Slurm nodelist, e.g. node[01-16]")
+
+var logLevel = flag.String(
+	"log.level",
+	"info",
+	"Only log messages with the given severity or above: debug, info, warn, error or fatal")
+
+var slurmPartition = flag.String(
+	"slurm.partition",
+	"",
+	"Limit the node metrics to the nodes of a Slurm partition, e.g. gpu")
+
+var nodeSource = flag.String(
+	"node-source",
+	"sinfo",
+	"Source of the node metrics: sinfo, or cross-check to also compare them against scontrol")
+
+var gpuDrain = flag.Bool(
+	"gpu-drain",
+	false,
+	"Export the drained GPUs of every node, from the GresDrain field of scontrol")
+
+var nodeTRES = flag.Bool(
+	"node-tres",
+	false,
+	"Export the configured and allocated TRES of every node, from the CfgTRES and AllocTRES fields of scontrol")
+
+var featuresAsLabel = flag.Bool(
+	"features-as-label",
+	false,
+	"Export slurm_node_info with the features of every node joined into a single label")
+
+var nodePartitionLabel = flag.Bool(
+	"node-partition-label",
+	false,
+	"Add a partition label to the CPU and memory metrics of the nodes, a node in several partitions is exported once per partition")
+
+var gpuTRES = flag.Bool(
+	"gpu-tres",
+	false,
+	"Take the allocated and total GPUs of the nodes, overall and per type, from the AllocTRES and CfgTRES of scontrol instead of their Gres")
+
+var gpuSuppressIdleIndex = flag.Bool(
+	"gpu-suppress-idle-index",
+	false,
+	"Export slurm_node_gpu_alloc only for the allocated GPU indices, idle indices are left out instead of 0")
+
+var gpuIndexRefreshInterval = flag.Duration(
+	"gpu-index-refresh-interval",
+	0,
+	"Refresh the per-index slurm_node_gpu_alloc series only once per interval instead of every scrape, 0 refreshes them every scrape")
+
+var availableStatesList = flag.String(
+	"available-states",
+	"idle,mixed",
+	"Base states of the nodes which accept new jobs, for the schedulable CPUs and the allocatable cluster capacity, e.g. idle,mixed,completing")
+
+var gpuMIGMemory = flag.String(
+	"gpu-mig-memory",
+	"",
+	"Memory of the MIG profiles, e.g. a100_1g.5gb=4864M,a100_3g.20gb=20G, profiles not listed use the memory in their name")
+
+// Megabytes per MIG profile from -gpu-mig-memory
+var migProfiles map[string]uint64
+
+var memUnit = flag.String(
+	"mem-unit",
+	"MiB",
+	"Size of a Slurm megabyte when converting memory to bytes: MiB (1<<20) or MB (1e6)")
+
+var statusLabelMode = flag.String(
+	"status-label-mode",
+	"full",
+	"Status label of the CPU and memory metrics of the nodes: full state with its flags, base state without flags (idle* is idle) or none to leave it out")
+
+var partitionGPUs = flag.Bool(
+	"partition-gpus",
+	false,
+	"Export the allocated and idle GPUs per partition, nodes in several partitions count towards each of them")
+
+var allocIdleLoad = flag.Float64(
+	"alloc-idle-load",
+	0.05,
+	"CPU load per allocated CPU below which a fully allocated node is considered idle")
+
+var gpuFlapThreshold = flag.Int(
+	"gpu-flap-threshold",
+	3,
+	"Number of GPU allocation changes of a node within the flap interval before they are counted as flaps")
+
+var gpuFlapInterval = flag.Duration(
+	"gpu-flap-interval",
+	5*time.Minute,
+	"Interval in which the GPU allocation changes of a node are counted for the flap detection")
+
+var forceGzip = flag.Bool(
+	"web.force-gzip",
+	false,
+	"Always gzip compress the metrics, even if the client does not request it")
+
+var heartbeatInterval = flag.Duration(
+	"heartbeat.interval",
+	0,
+	"Interval of the slurm_exporter_heartbeat timestamp, disabled if 0")
+
+var cluster = flag.String(
+	"cluster",
+	"",
+	"Name of the cluster, added as cluster label to all metrics")
+
+var autoClusterLabel = flag.Bool(
+	"auto-cluster-label",
+	false,
+	"Detect the name of the cluster with scontrol and add it as cluster label to all metrics")
+
+var dumpJSON = flag.Bool(
+	"dump-json",
+	false,
+	"Collect all metrics once, print them as JSON and exit")
+
+var pushGateway = flag.String(
+	"push-gateway",
+	"",
+	"URL of a Pushgateway to push the metrics to, instead of serving them over HTTP")
+
+var pushJob = flag.String(
+	"push-gateway.job",
+	"slurm_exporter",
+	"Job name used when pushing the metrics to the Pushgateway")
+
+var pushInterval = flag.Duration(
+	"push-gateway.interval",
+	time.Minute,
+	"Interval between two pushes of the metrics to the Pushgateway")
+
+var metricsWhitelist = flag.String(
+	"metrics-whitelist",
+	"",
+	"Comma separated list of the only metrics to export, e.g. slurm_node_cpu_alloc,slurm_node_gpu_alloc")
+
+var slurmSource = flag.String(
+	"slurm.source",
+	"",
+	"Backend of the node, partitions, job and queue collectors: exec to execute the Slurm commands or rest to query slurmrestd, defaults to rest if the URL of slurmrestd is set")
+
+var slurmFormat = flag.String(
+	"slurm.format",
+	"text",
+	"Output of sinfo parsed for the node metrics: text, or json with sinfo --json of Slurm 21.08 and later")
+
+var slurmrestdURL = flag.String(
+	"slurmrestd-url",
+	"",
+	"URL of slurmrestd, e.g. http://slurmctl:6820, to read the node metrics from its REST API instead of executing sinfo")
+
+var slurmrestdToken = flag.String(
+	"slurmrestd-token",
+	"",
+	"JWT to authenticate with slurmrestd, defaults to the SLURM_JWT environment variable")
+
+var slurmrestdVersion = flag.String(
+	"slurmrestd-version",
+	"",
+	"Version of the slurmrestd API, defaults to the API of the release of Slurm or v0.0.40 if unknown")
+
+var parserVersion = flag.String(
+	"parser-version",
+	"",
+	"Release of Slurm whose output is parsed, e.g. 22.05 or 23.11, instead of the version detected with sinfo --version")
+
+var slurmTimeout = flag.Duration(
+	"slurm.timeout",
+	10*time.Second,
+	"Timeout of the Slurm commands, 0 to wait forever")
+
+var slurmCacheTTL = flag.Duration(
+	"slurm.cache-ttl",
+	0,
+	"Serve the node metrics read from Slurm for this long instead of running sinfo on every scrape, 0 disables the cache")
+
+// Names of the collectors as used by the shared command helper in command.go
+var collectorNames = []string{
+	"accounts", "cluster", "cpus", "energy", "fairshare", "gpu_stranded", "gpus", "job", "licenses", "node", "node_jobs", "node_reasons", "nodes",
+	"partitions", "qos", "qos_limits", "queue", "reservations", "sacct", "scheduler", "sreport", "topology", "users", "version",
+}
+
+// Timeouts of the single collectors, overriding -slurm.timeout
+var collectorTimeouts = make(map[string]*time.Duration)
+
+// Collectors enabled by default, each can be turned off with -collector.<name>=false
+var defaultCollectors = []string{
+	"accounts", "cpus", "fairshare", "job", "node", "nodes", "partitions", "qos", "queue", "scheduler", "users",
+}
+
+// Flags of the default collectors by name
+var collectorEnabled = make(map[string]*bool)
+
+// Slurm commands executed by the collectors, each with a configurable binary
+var slurmCommands = []string{"sacct", "sacctmgr", "scontrol", "sdiag", "sinfo", "squeue", "sreport", "sshare"}
+
+// Binaries of the Slurm commands, used by CommandPath in command.go
+var commandPaths = make(map[string]*string)
+
+func init() {
+	flag.StringVar(slurmrestdURL, "slurm.rest-url", "", "Alias of -slurmrestd-url")
+	flag.StringVar(listenAddress, "web.listen-address", ":8080", "Alias of -listen-address")
+	for _, command := range slurmCommands {
+		commandPaths[command] = flag.String(
+			"slurm."+command+"-path",
+			"",
+			"Binary of "+command+", defaults to "+command+" in the PATH")
+	}
+	for _, name := range defaultCollectors {
+		collectorEnabled[name] = flag.Bool(
+			"collector."+name,
+			true,
+			"Enable the "+name+" collector")
+	}
+	flag.BoolVar(collectorEnabled["job"], "collector.jobs", true, "Alias of -collector.job")
+	for _, name := range collectorNames {
+		collectorTimeouts[name] = flag.Duration(
+			"collector."+name+".timeout",
+			0,
+			"Timeout of the Slurm commands of the "+name+" collector, defaults to -slurm.timeout")
+	}
+	for _, name := range restCollectors {
+		collectorSources[name] = flag.String(
+			"collector."+name+".source",
+			"",
+			"Backend of the "+name+" collector, exec or rest, defaults to -slurm.source")
+	}
+}
+
+func registerCollectors(r prometheus.Registerer) {
+	// Metrics have to be registered to be exposed
+	if *collectorEnabled["accounts"] {
+		r.MustRegister(NewAccountsCollector())   // from accounts.go
+	}
+	if *collectorEnabled["cpus"] {
+		r.MustRegister(NewCPUsCollector())       // from cpus.go
+	}
+	if *collectorEnabled["nodes"] {
+		r.MustRegister(NewNodesCollector())      // from nodes.go
+	}
+	if *collectorEnabled["node"] {
+		r.MustRegister(NewNodeCollector())       // from node.go
+	}
+	if *collectorEnabled["job"] {
+		r.MustRegister(NewJobCollector())        // from job.go
+	}
+	if *collectorEnabled["partitions"] {
+		r.MustRegister(NewPartitionsCollector()) // from partitions.go
+	}
+	if *collectorEnabled["queue"] {
+		r.MustRegister(NewQueueCollector())      // from queue.go
+	}
+	if *collectorEnabled["qos"] {
+		r.MustRegister(NewQOSCollector())        // from qos.go
+	}
+	if *collectorEnabled["scheduler"] {
+		r.MustRegister(NewSchedulerCollector())  // from scheduler.go
+	}
+	if *collectorEnabled["fairshare"] {
+		r.MustRegister(NewFairShareCollector())  // from sshare.go
+	}
+	if *collectorEnabled["users"] {
+		r.MustRegister(NewUsersCollector())      // from users.go
+	}
+	r.MustRegister(NewVersionCollector(slurmVersion)) // from version.go
+	r.MustRegister(scrapesTotal)                 // from exporter.go
+	r.MustRegister(collectorLastSuccess)         // from command.go
+	r.MustRegister(collectorPermissionDenied)    // from command.go
 
 	// Turn on GPUs accounting only if the corresponding command line option is set to true.
 	if *gpuAcct {
-		prometheus.MustRegister(NewGPUsCollector())   // from gpus.go
+		r.MustRegister(NewGPUsCollector())       // from gpus.go
+	}
+	if *sacctAcct {
+		r.MustRegister(NewAccountingCollector()) // from accounting.go
+	}
+	if *nodeJobInfo {
+		r.MustRegister(NewNodeJobsCollector())   // from node_jobs.go
+	}
+	if *energyInfo {
+		r.MustRegister(NewEnergyCollector())     // from energy.go
+	}
+	if *topologyInfo {
+		r.MustRegister(NewTopologyCollector())   // from topology.go
+	}
+	if *sreportInfo {
+		r.MustRegister(NewReportCollector(*sreportInterval)) // from sreport.go
+	}
+	if *reservationsInfo {
+		r.MustRegister(NewReservationsCollector()) // from reservation.go
+	}
+	if *qosLimitsInfo {
+		r.MustRegister(NewQOSLimitsCollector()) // from qos_limits.go
+	}
+	if *nodeReasonsInfo {
+		r.MustRegister(NewNodeReasonsCollector()) // from node_reasons.go
+	}
+	if *licensesInfo {
+		r.MustRegister(NewLicensesCollector()) // from licenses.go
+	}
+	if *strandedGPUsInfo {
+		r.MustRegister(NewStrandedGPUsCollector()) // from gpu_stranded.go
+	}
+}
+
+func main() {
+	flag.Parse()
+	if err := log.Base().SetLevel(*logLevel); err != nil {
+		log.Fatalf("Invalid -log.level: %v", err)
+	}
+	if *nodeSource != "sinfo" && *nodeSource != "cross-check" {
+		log.Fatalf("Invalid -node-source %q, expected sinfo or cross-check", *nodeSource)
+	}
+	if _, ok := memUnitBytes[*memUnit]; !ok {
+		log.Fatalf("Invalid -mem-unit %q, expected MiB or MB", *memUnit)
+	}
+	if *statusLabelMode != "full" && *statusLabelMode != "base" && *statusLabelMode != "none" {
+		log.Fatalf("Invalid -status-label-mode %q, expected full, base or none", *statusLabelMode)
+	}
+	if !strings.HasPrefix(*telemetryPath, "/") {
+		log.Fatalf("Invalid -web.telemetry-path %q, expected a path starting with /", *telemetryPath)
+	}
+	profiles, err := ParseMIGProfiles(*gpuMIGMemory)
+	if err != nil {
+		log.Fatalf("Invalid -gpu-mig-memory: %v", err)
+	}
+	migProfiles = profiles
+	states, err := ParseAvailableStates(*availableStatesList)
+	if err != nil {
+		log.Fatalf("Invalid -available-states: %v", err)
+	}
+	availableStates = states
+
+	slurmVersion = DetectSlurmVersion() // from version.go
+	release, err := ParserVersion(slurmVersion)
+	if err != nil {
+		log.Fatalf("Invalid -parser-version: %v", err)
+	}
+	if release != "" {
+		log.Infof("Parsing the output of Slurm %s", release)
+	}
+	if err := CheckFormat(*slurmFormat, release); err != nil {
+		log.Fatalf("Invalid -slurm.format: %v", err)
+	}
+	apiVersion := *slurmrestdVersion
+	if apiVersion == "" {
+		apiVersion = RESTAPIVersion(release)
+	}
+
+	token := *slurmrestdToken
+	if token == "" {
+		token = os.Getenv("SLURM_JWT")
+	}
+	source, err := NewNodeDataSource(CollectorSource("node"), *slurmFormat, *slurmrestdURL, token, apiVersion, release) // from rest.go
+	if err != nil {
+		log.Fatalf("Invalid source of the node collector: %v", err)
+	}
+	if _, ok := source.(RESTNodeSource); ok {
+		log.Infof("Reading the nodes from %s", *slurmrestdURL)
+	}
+	nodeDataSource = source
+	partitionDataSource, err = NewPartitionDataSource(CollectorSource("partitions"), *slurmrestdURL, token, apiVersion, release)
+	if err != nil {
+		log.Fatalf("Invalid source of the partitions collector: %v", err)
+	}
+	jobDataSource, err = NewJobDataSource("job", CollectorSource("job"), *slurmrestdURL, token, apiVersion)
+	if err != nil {
+		log.Fatalf("Invalid source of the job collector: %v", err)
+	}
+	queueDataSource, err = NewJobDataSource("queue", CollectorSource("queue"), *slurmrestdURL, token, apiVersion)
+	if err != nil {
+		log.Fatalf("Invalid source of the queue collector: %v", err)
+	}
+	nodeCache = NewNodeCache(*slurmCacheTTL) // from node.go
+
+	var registerer prometheus.Registerer = prometheus.DefaultRegisterer
+	var whitelist *WhitelistRegisterer
+	if *metricsWhitelist != "" {
+		whitelist = NewWhitelistRegisterer(ParseWhitelist(*metricsWhitelist), registerer) // from whitelist.go
+		whitelist.RegisterRuntimeCollectors()
+		registerer = whitelist
+	}
+	clusterLabel := *cluster
+	if clusterLabel == "" && *autoClusterLabel {
+		clusterLabel = DetectClusterName() // from cluster.go
+	}
+	if clusterLabel != "" {
+		log.Infof("Cluster: %s", clusterLabel)
+		registerer = prometheus.WrapRegistererWith(prometheus.Labels{"cluster": clusterLabel}, registerer)
+	}
+	registerCollectors(registerer)
+	if *heartbeatInterval > 0 {
+		registerer.MustRegister(heartbeat) // from exporter.go
+		go HeartbeatLoop(*heartbeatInterval)
+	}
+	// Registers the promhttp metrics before the whitelist is checked, with the cluster label
+	metricsHandler := MetricsHandler(registerer, prometheus.DefaultGatherer, *forceGzip) // from exporter.go
+	if whitelist != nil {
+		if unknown := whitelist.Unknown(); len(unknown) > 0 {
+			log.Fatalf("Unknown metrics in -metrics-whitelist: %s", strings.Join(unknown, ","))
+		}
+	}
+
+	// One-shot mode for scripting, snapshots and support bundles
+	if *dumpJSON {
+		if err := DumpJSON(prometheus.DefaultGatherer, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
+	// Batch and ephemeral environments push the metrics instead of being scraped
+	if *pushGateway != "" {
+		log.Infof("Pushing metrics to %s every %s", *pushGateway, *pushInterval)
+		PushLoop(*pushGateway, *pushJob, prometheus.DefaultGatherer, *pushInterval)
 	}
 
 	// The Handler function provides a default handler to expose metrics
 	// via an HTTP server. "/metrics" is the usual endpoint for that.
-	log.Infof("Starting Server: %s", *listenAddress)
+	log.Infof("Starting Server: %s%s", *listenAddress, *telemetryPath)
 	log.Infof("GPUs Accounting: %t", *gpuAcct)
-	http.Handle("/metrics", promhttp.Handler())
-	log.Fatal(http.ListenAndServe(*listenAddress, nil))
+	log.Infof("Jobs Accounting: %t", *sacctAcct)
+	http.Handle(*telemetryPath, metricsHandler)
+	if *telemetryPath != "/" {
+		http.Handle("/", LandingPage(*telemetryPath)) // from exporter.go
+	}
+	log.Fatal(ListenAndServe(*listenAddress, *tlsCert, *tlsKey, *tlsClientCA, nil)) // from exporter.go
 }
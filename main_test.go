@@ -0,0 +1,60 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterCollectorsDisabled(t *testing.T) {
+	for _, name := range defaultCollectors {
+		defer func(name string, enabled bool) { *collectorEnabled[name] = enabled }(name, *collectorEnabled[name])
+		*collectorEnabled[name] = false
+	}
+	registry := prometheus.NewRegistry()
+	registerCollectors(registry)
+
+	// Only the metrics of the exporter itself and the Slurm version are left
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	for _, family := range families {
+		name := family.GetName()
+		assert.True(t, strings.HasPrefix(name, "slurm_exporter_") || name == "slurm_version_info", name)
+	}
+}
+
+func TestCollectorFlagNames(t *testing.T) {
+	// The enable and the timeout flag of a collector use the same name
+	flag.VisitAll(func(f *flag.Flag) {
+		name := strings.TrimPrefix(f.Name, "collector.")
+		if name == f.Name || strings.Contains(name, ".") || strings.HasPrefix(f.Usage, "Alias of") {
+			return
+		}
+		assert.Contains(t, collectorNames, name, f.Name)
+		assert.NotNil(t, flag.Lookup(f.Name+".timeout"), f.Name)
+	})
+}
+
+func TestCollectorJobsAlias(t *testing.T) {
+	defer flag.Set("collector.job", "true")
+	assert.NoError(t, flag.Set("collector.jobs", "false"))
+	assert.False(t, *collectorEnabled["job"])
+}
@@ -16,13 +16,17 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>. */
 package main
 
 import (
-	"log"
-	"os/exec"
+	"fmt"
+	"math"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
 )
 
 // NodeMetrics stores metrics for each node
@@ -31,21 +35,356 @@ type NodeMetrics struct {
 	cpuIdle  uint64
 	cpuOther uint64
 	cpuTotal uint64
+	cpuLoad  float64
+	// Unset for the "N/A" load of down or unreachable nodes
+	hasLoad bool
+
+	sockets uint64
+	cores   uint64
+	threads uint64
 
 	memAlloc uint64
 	memTotal uint64
+	memFree  uint64
 
 	gpuAlloc uint64
+	gpuTotal uint64
 
 	hasGPU bool
-	gpuType string
-	gpuIndex []int
+
+	// GPUs per type in the order of the Gres, several types on a node are
+	// MIG profiles or different models, e.g. "gpu:a100:4,gpu:v100:4"
+	gpus []*GPUEntry
+
+	// MPS shares per GPU type, usually 100 per GPU
+	mpsTotal map[string]uint64
+	mpsAlloc map[string]uint64
 
 	nodeStatus string
+	nodeState  string
+	// State flags of nodeStatus by name, e.g. "unreachable" for "idle*"
+	nodeFlags map[string]bool
+
+	// Comma separated available features, e.g. "avx512,ib"
+	features string
+	// Comma separated active features, a subset of the available ones on
+	// nodes which change their features on reboot
+	activeFeatures string
+	// Partitions of the node, without the "*" of the default partition
+	partitions []string
+
+	// Set if sinfo reported the node with different totals on several lines
+	conflicting bool
+
+	// Set if the number of allocated GPU indices differs from the allocated GPUs
+	gpuIndexMismatch bool
+}
+
+// GPUEntry holds the GPUs of one type of a node. Slurm numbers the GPUs of
+// a node across all of its types, the indices of a type start at first.
+type GPUEntry struct {
+	gpuType string
+	alloc   uint64
+	total   uint64
+	first   int
+	// 1 for every allocated and 0 for every idle GPU of the type
+	index []int
+}
+
+// Fragmented reports if a GPU type of a node has both allocated and idle GPUs
+func (e *GPUEntry) Fragmented() bool {
+	return e.alloc > 0 && e.alloc < e.total
+}
+
+// Percent returns the fraction of the GPUs of the type which are allocated, between 0 and 1
+func (e *GPUEntry) Percent() float64 {
+	if e.total == 0 {
+		return 0
+	}
+	return math.Min(float64(e.alloc)/float64(e.total), 1)
+}
+
+// Base node states as reported by sinfo, any other state is reported as "other"
+var nodeBaseStates = []string{
+	"allocated", "completing", "down", "drained", "draining", "fail", "failing",
+	"future", "idle", "maint", "mixed", "planned", "reboot", "reserved", "unknown",
+}
+
+// SplitNodeState separates the base state of a node from the flags Slurm
+// appends to it, e.g. "idle*" (not responding) or "mixed~" (powered off)
+func SplitNodeState(status string) (string, string) {
+	status = strings.ToLower(strings.TrimSpace(status))
+	base := strings.TrimRight(status, "*~#!%$@^-+")
+	flags := status[len(base):]
+	// "mixed+drain" style states carry their flags after a plus sign
+	if i := strings.Index(base, "+"); i >= 0 {
+		flags = base[i:] + flags
+		base = base[:i]
+	}
+	return base, flags
+}
+
+// NodeStateCounts counts the nodes per state, the state as reported by sinfo
+// without the symbols appended to it, e.g. "idle*" and "idle" are both "idle"
+// while "mixed+drain" is kept apart from "mixed"
+func NodeStateCounts(nodes map[string]*NodeMetrics) map[string]float64 {
+	counts := make(map[string]float64)
+	for _, node := range nodes {
+		state := strings.TrimRight(strings.ToLower(strings.TrimSpace(node.nodeStatus)), "*~#!%$@^-")
+		counts[state]++
+	}
+	return counts
+}
+
+// NodeBaseState returns the base state of a node, without any flags
+func NodeBaseState(status string) string {
+	base, _ := SplitNodeState(status)
+	for _, state := range nodeBaseStates {
+		if base == state {
+			return state
+		}
+	}
+	return "other"
+}
+
+// NodePowerState returns the power saving transition of a node from its
+// state flags, "#" (powering_up), "~" (powered_down) or "%" (powering_down),
+// and an empty string for nodes which are simply powered on
+func NodePowerState(status string) string {
+	_, flags := SplitNodeState(status)
+	switch {
+	case strings.Contains(flags, "#") || strings.Contains(flags, "powering_up"):
+		return "powering_up"
+	case strings.Contains(flags, "%") || strings.Contains(flags, "powering_down"):
+		return "powering_down"
+	case strings.Contains(flags, "~") || strings.Contains(flags, "powered_down"):
+		return "powered_down"
+	}
+	return ""
+}
+
+// State flags appended to the base state by sinfo as symbols, and by
+// slurmrestd as words, with the name of their slurm_node_flag_* metric
+var nodeStateFlags = []struct {
+	name    string
+	symbols string
+	words   []string
+}{
+	{"unreachable", "*", []string{"not_responding"}},
+	{"powered_down", "~", []string{"powered_down"}},
+	{"powering_up", "#", []string{"powering_up"}},
+	{"powering_down", "%!", []string{"powering_down", "power_down"}},
+	{"maintenance", "$", []string{"maintenance"}},
+	{"reboot", "@^", []string{"reboot_requested", "reboot_issued"}},
+	{"planned", "-", []string{"planned"}},
+}
+
+// NodeFlags returns the state flags of a node by name, e.g. "unreachable"
+// and "maintenance" for "down*$", every known flag is set to true or false
+func NodeFlags(status string) map[string]bool {
+	_, flags := SplitNodeState(status)
+	words := make(map[string]bool)
+	for _, word := range strings.Split(flags, "+") {
+		words[strings.Trim(word, "*~#!%$@^-")] = true
+	}
+	set := make(map[string]bool, len(nodeStateFlags))
+	for _, flag := range nodeStateFlags {
+		set[flag.name] = strings.ContainsAny(flags, flag.symbols)
+		for _, word := range flag.words {
+			set[flag.name] = set[flag.name] || words[word]
+		}
+	}
+	return set
+}
+
+// Base states of the nodes which accept new jobs, set with -available-states
+var availableStates = map[string]bool{"idle": true, "mixed": true}
+
+// ParseAvailableStates takes a list of base states such as "idle,mixed"
+// and returns them as a set, unknown states are an error
+func ParseAvailableStates(list string) (map[string]bool, error) {
+	states := make(map[string]bool)
+	for _, state := range strings.Split(list, ",") {
+		state = strings.ToLower(strings.TrimSpace(state))
+		if state == "" {
+			continue
+		}
+		if NodeBaseState(state) != state {
+			return nil, fmt.Errorf("unknown node state %q", state)
+		}
+		states[state] = true
+	}
+	return states, nil
+}
+
+// NodeSchedulable reports if a node in the given state accepts new jobs,
+// an idle node which is drained or not responding does not
+func NodeSchedulable(status string) bool {
+	base, flags := SplitNodeState(status)
+	if !availableStates[base] {
+		return false
+	}
+	return !strings.Contains(flags, "drain") && !strings.Contains(flags, "*")
+}
+
+// Schedulable reports if the resources of a node count as allocatable:
+// nodes in one of the -available-states, and allocated nodes which accept
+// new jobs again once theirs end, unless drained or not responding
+func (nm *NodeMetrics) Schedulable() bool {
+	base, flags := SplitNodeState(nm.nodeStatus)
+	if !availableStates[base] && base != "allocated" {
+		return false
+	}
+	return !strings.Contains(flags, "drain") && !strings.Contains(flags, "*")
+}
+
+// Unavailable reports if a node is down, drained or failed, so its
+// resources can not be scheduled at all
+func (nm *NodeMetrics) Unavailable() bool {
+	base, flags := SplitNodeState(nm.nodeStatus)
+	switch base {
+	case "down", "drained", "draining", "fail", "failing":
+		return true
+	}
+	return strings.Contains(flags, "drain")
+}
+
+// GPUPercent returns the fraction of the GPUs of a node which are allocated,
+// between 0 and 1, and 0 for nodes without GPUs
+func (nm *NodeMetrics) GPUPercent() float64 {
+	if nm.gpuTotal == 0 {
+		return 0
+	}
+	return math.Min(float64(nm.gpuAlloc)/float64(nm.gpuTotal), 1)
+}
+
+// IdleAvailable reports if a node is idle and accepts jobs, as opposed
+// to idle nodes which are drained or not responding
+func (nm *NodeMetrics) IdleAvailable() bool {
+	return nm.nodeState == "idle" && NodeSchedulable(nm.nodeStatus)
+}
+
+// CPUConfigMismatch reports if the total CPUs of a node differ from its
+// sockets*cores*threads topology, e.g. after booting with disabled cores
+func (nm *NodeMetrics) CPUConfigMismatch() bool {
+	if nm.sockets == 0 || nm.cores == 0 || nm.threads == 0 {
+		return false
+	}
+	return nm.sockets*nm.cores*nm.threads != nm.cpuTotal
+}
+
+// CoresTotal returns the physical cores of a node, sockets*cores
+func (nm *NodeMetrics) CoresTotal() uint64 {
+	return nm.sockets * nm.cores
+}
+
+// ThreadsTotal returns the hardware threads of a node, sockets*cores*threads,
+// on nodes with ThreadsPerCore above 1 a job asking for cores gets fewer CPUs
+func (nm *NodeMetrics) ThreadsTotal() uint64 {
+	return nm.sockets * nm.cores * nm.threads
+}
+
+// SchedulableCPUs returns the idle CPUs of a node which can be used by jobs right now
+func (nm *NodeMetrics) SchedulableCPUs() uint64 {
+	if NodeSchedulable(nm.nodeStatus) {
+		return nm.cpuIdle
+	}
+	return 0
+}
+
+// CPUOvercommit returns the CPUs allocated beyond the total of an
+// oversubscribed node, 0 if the allocation fits
+func (nm *NodeMetrics) CPUOvercommit() uint64 {
+	if nm.cpuAlloc > nm.cpuTotal {
+		return nm.cpuAlloc - nm.cpuTotal
+	}
+	return 0
+}
+
+// Source of the node metrics, replaced in main() when slurmrestd is used
+var nodeDataSource NodeDataSource = ExecNodeSource{}
+
+func NodeGetMetrics() (map[string]*NodeMetrics, error) {
+	nodes, _, err := NodeGetMetricsTimed()
+	return nodes, err
+}
+
+// NodeCollectTimes is the time spent reading the node data from Slurm
+// (exec) and parsing it (parse)
+type NodeCollectTimes struct {
+	exec  time.Duration
+	parse time.Duration
+}
+
+// NodeGetMetricsTimed returns the node metrics like NodeGetMetrics and the
+// time spent in each phase, also for the phases which failed
+func NodeGetMetricsTimed() (map[string]*NodeMetrics, NodeCollectTimes, error) {
+	return nodeCache.Get(time.Now(), readNodeMetrics)
+}
+
+// Cache of the node metrics, replaced in main() with the -slurm.cache-ttl
+var nodeCache = NewNodeCache(0)
+
+// NodeCache keeps the last node metrics read from Slurm, so that several
+// Prometheus replicas scraping the exporter run sinfo only once per TTL
+type NodeCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	lastRun time.Time
+	nodes   map[string]*NodeMetrics
+	times   NodeCollectTimes
+	err     error
 }
 
-func NodeGetMetrics() map[string]*NodeMetrics {
-	return ParseNodeMetrics(NodeData())
+func NewNodeCache(ttl time.Duration) *NodeCache {
+	return &NodeCache{ttl: ttl}
+}
+
+// Get returns the cached node metrics while they are younger than the TTL
+// and reads them with fetch otherwise, always with a TTL of 0. Overlapping
+// scrapes wait for the same read. If the read fails, the last good metrics
+// are returned together with the error.
+func (c *NodeCache) Get(now time.Time, fetch func() (map[string]*NodeMetrics, NodeCollectTimes, error)) (map[string]*NodeMetrics, NodeCollectTimes, error) {
+	if c.ttl <= 0 {
+		return fetch()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastRun.IsZero() || now.Sub(c.lastRun) >= c.ttl {
+		nodes, times, err := fetch()
+		if err == nil {
+			c.nodes = nodes
+		}
+		// A failed read is cached too, not to retry a struggling controller
+		// on every scrape
+		c.times, c.err = times, err
+		c.lastRun = now
+	}
+	return c.nodes, c.times, c.err
+}
+
+// readNodeMetrics reads and parses the node data of the node data source
+func readNodeMetrics() (map[string]*NodeMetrics, NodeCollectTimes, error) {
+	var times NodeCollectTimes
+	start := time.Now()
+	data, err := nodeDataSource.Read()
+	times.exec = time.Since(start)
+	if err != nil {
+		return nil, times, err
+	}
+	start = time.Now()
+	nodes, err := nodeDataSource.Parse(data)
+	times.parse = time.Since(start)
+	// slurmrestd and sinfo --json list the TRES with the nodes, the text
+	// output of sinfo needs scontrol for them
+	if err == nil && *gpuTRES {
+		if _, ok := nodeDataSource.(ExecNodeSource); ok {
+			start = time.Now()
+			ApplyGPUTRES(nodes, ParseScontrolNodes(ScontrolNodesData("node")))
+			times.exec += time.Since(start)
+		}
+	}
+	return nodes, times, err
 }
 
 // ParseNodeMetrics takes the output of sinfo with node data
@@ -54,30 +393,48 @@ func ParseNodeMetrics(input []byte) map[string]*NodeMetrics {
 	nodes := make(map[string]*NodeMetrics)
 	lines := strings.Split(string(input), "\n")
 
-	// Sort and remove all the duplicates from the 'sinfo' output
-	sort.Strings(lines)
-	linesUniq := RemoveDuplicates(lines)
-
-	for _, line := range linesUniq {
+	// A node is listed once per partition: identical lines are parsed only
+	// once, of differing lines of a node the last one wins
+	seen := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		// Output piped through some tools ends its lines with "\r\n"
+		line = strings.TrimRight(line, "\r")
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
 		node := strings.Fields(line)
+		// Columns go missing e.g. during a restart of the controller,
+		// such lines are skipped and the other nodes still reported
+		if len(node) < 7 {
+			log.With("line", line).Warnf("Skipping sinfo line with %d instead of at least 7 fields", len(node))
+			continue
+		}
+		cpuInfo := strings.Split(node[3], "/")
+		if len(cpuInfo) < 4 {
+			log.With("node", node[0]).With("line", line).Warnf("Skipping sinfo line with invalid CPU states %q", node[3])
+			continue
+		}
 		nodeName := node[0]
-		nodes[nodeName] = &NodeMetrics{0, 0, 0, 0, 0, 0, 0, false, "", nil, ""}
+		previous := nodes[nodeName]
+		nodes[nodeName] = &NodeMetrics{}
 
 
 		// Status Info
 		nodes[nodeName].nodeStatus = node[4] // mixed, allocated, etc.
+		nodes[nodeName].nodeState = NodeBaseState(node[4])
+		nodes[nodeName].nodeFlags = NodeFlags(node[4])
 
 
 		// Memory Info
-		memAlloc, _ := strconv.ParseUint(node[1], 10, 64)
-		memTotal, _ := strconv.ParseUint(node[2], 10, 64)
+		memAlloc, _ := ParseMemory(node[1])
+		memTotal, _ := ParseMemory(node[2])
 
 		nodes[nodeName].memAlloc = memAlloc
 		nodes[nodeName].memTotal = memTotal
 
 
 		// CPU Info
-		cpuInfo := strings.Split(node[3], "/")
 		cpuAlloc, _ := strconv.ParseUint(cpuInfo[0], 10, 64)
 		cpuIdle, _ := strconv.ParseUint(cpuInfo[1], 10, 64)
 		cpuOther, _ := strconv.ParseUint(cpuInfo[2], 10, 64)
@@ -89,124 +446,1360 @@ func ParseNodeMetrics(input []byte) map[string]*NodeMetrics {
 		nodes[nodeName].cpuTotal = cpuTotal
 
 
+		// CPU Load, "N/A" for down or unreachable nodes
+		if len(node) > 7 {
+			load, err := strconv.ParseFloat(node[7], 64)
+			nodes[nodeName].cpuLoad, nodes[nodeName].hasLoad = load, err == nil
+		}
+
+
+		// CPU Topology
+		if len(node) > 10 {
+			nodes[nodeName].sockets, _ = strconv.ParseUint(node[8], 10, 64)
+			nodes[nodeName].cores, _ = strconv.ParseUint(node[9], 10, 64)
+			nodes[nodeName].threads, _ = strconv.ParseUint(node[10], 10, 64)
+		}
+
+
+		// Features, "(null)" for nodes without any
+		if len(node) > 11 && node[11] != "(null)" {
+			nodes[nodeName].features = node[11]
+		}
+
+
+		// Free memory of the OS, "N/A" for unreachable nodes
+		if len(node) > 12 && node[12] != "N/A" {
+			nodes[nodeName].memFree, _ = ParseMemory(node[12])
+		}
+
+
+		// Partition of the line, a node is listed once per partition
+		if previous != nil {
+			nodes[nodeName].partitions = previous.partitions
+		}
+		if len(node) > 13 {
+			nodes[nodeName].addPartition(node[13])
+		}
+
+
+		// Active features, "(null)" for nodes without any
+		if len(node) > 14 && node[14] != "(null)" {
+			nodes[nodeName].activeFeatures = node[14]
+		}
+
+
 		// GPU Info
-		gpuTotalStr := node[5] // "gpu:a100:8" or "(null)" if no GPUs
-		gpuAllocStr := node[6] // "gpu:a100:6(IDX:0,2-6)" - multiple, non-contiguous
-							  // "gpu:a100:6(IDX:0,2-3,6)" - multiple, non-contiguous
-							  // "gpu:a100:8(IDX:0-7)" - multiple, contiguous
-							  // "gpu:ada6000:1(IDX:0)" - single
-							  // "gpu:k80:0(IDX:N/A)" - none
-		
-		if (gpuTotalStr != "(null)") { // Has GPU
-			nodes[nodeName].hasGPU = true
-			gpu_str := strings.Split(gpuAllocStr, "(")
-			usedGPUs := strings.Split(gpu_str[0], ":") // gpu:a100:6
-			nodes[nodeName].gpuType = usedGPUs[1]
-
-			nodes[nodeName].gpuAlloc, _ = strconv.ParseUint(usedGPUs[2], 10, 64)
-			num_gpus, _ := strconv.ParseUint(strings.Split(gpuTotalStr, ":")[2], 10, 64)
-
-			// index_list = IDX:0,2-6
-						 // IDX:0,2-3,6
-						 // IDX:0-7
-						 // IDX:0
-						 // IDX:N/A
-			index_list := strings.TrimSuffix(gpu_str[1], ")")
-			index_list = strings.Split(index_list, ":")[1]
-
-			nodes[nodeName].gpuIndex = make([]int, num_gpus)
-			if (index_list != "N/A") {
-				for _, part := range strings.Split(index_list, ",") {
-					if strings.Contains(part, "-") {
-						// Range
-						bounds := strings.Split(part, "-")
-						start, _ := strconv.Atoi(bounds[0])
-						end, _ := strconv.Atoi(bounds[1])
-						for i := start; i <= end; i++ {
-							nodes[nodeName].gpuIndex[i] = 1
-						}
-					} else {
-						// Single Digit
-						num, _ := strconv.Atoi(part)
-						nodes[nodeName].gpuIndex[num] = 1
-					}
-				}
+		nodes[nodeName].parseGPUs(nodeName, node[5], node[6])
+
+
+		// Lines of the same node, e.g. one per partition, are expected to agree
+		if previous != nil {
+			nodes[nodeName].conflicting = previous.conflicting
+			if previous.cpuTotal != cpuTotal || previous.memTotal != memTotal {
+				log.With("node", nodeName).With("line", line).Warnf("Conflicting sinfo lines, %d/%d CPUs, %d/%d memory",
+					previous.cpuTotal, cpuTotal, previous.memTotal, memTotal)
+				nodes[nodeName].conflicting = true
 			}
 		}
 	}
 
+	// Guard against parser bugs and Slurm reporting oddities
+	for _, nm := range nodes {
+		if nm.hasGPU {
+			nm.gpuIndexMismatch = nm.allocatedIndices() != nm.gpuAlloc
+		}
+	}
+
 	return nodes
 }
 
+// allocatedIndices counts the allocated GPU indices of all types of the node
+func (nm *NodeMetrics) allocatedIndices() uint64 {
+	allocated := 0
+	for _, entry := range nm.gpus {
+		for _, used := range entry.index {
+			allocated += used
+		}
+	}
+	return uint64(allocated)
+}
+
+// applyGPUTRES replaces the allocated and total GPUs of the node, overall and
+// per type, with its allocated and configured TRES, e.g. "gres/gpu=4" and
+// "gres/gpu:a100=4". Nodes without GPUs in their TRES are left as they are.
+// The indices stay as read from the Gres, a type missing there gets its
+// GPUs listed as idle after the other types.
+func (nm *NodeMetrics) applyGPUTRES(cfg, alloc map[string]float64) {
+	total, ok := cfg["gres/gpu"]
+	if !ok {
+		return
+	}
+	nm.gpuTotal = uint64(total)
+	nm.gpuAlloc = uint64(alloc["gres/gpu"])
+	nm.hasGPU = nm.gpuTotal > 0
+	names := make([]string, 0, len(cfg))
+	for name := range cfg {
+		if strings.HasPrefix(name, "gres/gpu:") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		gpuType := strings.TrimPrefix(name, "gres/gpu:")
+		entry := nm.gpuEntry(gpuType)
+		if entry == nil {
+			first := 0
+			if n := len(nm.gpus); n > 0 {
+				first = nm.gpus[n-1].first + len(nm.gpus[n-1].index)
+			}
+			entry = &GPUEntry{gpuType: gpuType, first: first, index: make([]int, int(cfg[name]))}
+			nm.gpus = append(nm.gpus, entry)
+		}
+		entry.total = uint64(cfg[name])
+		entry.alloc = uint64(alloc[name])
+	}
+	nm.gpuIndexMismatch = nm.hasGPU && nm.allocatedIndices() != nm.gpuAlloc
+}
+
+// ApplyGPUTRES takes the scontrol details of the nodes and replaces the GPUs
+// of every node with its CfgTRES and AllocTRES, see applyGPUTRES
+func ApplyGPUTRES(nodes map[string]*NodeMetrics, scontrolNodes map[string]map[string]string) {
+	cfg := ParseNodeTRES(scontrolNodes, "CfgTRES")
+	alloc := ParseNodeTRES(scontrolNodes, "AllocTRES")
+	for name, nm := range nodes {
+		if tres, ok := cfg[name]; ok {
+			nm.applyGPUTRES(tres, alloc[name])
+		}
+	}
+}
+
+// addPartition adds a partition of the node, once
+func (nm *NodeMetrics) addPartition(partition string) {
+	partition = strings.TrimSuffix(partition, "*")
+	if partition == "" || partition == "(null)" {
+		return
+	}
+	for _, p := range nm.partitions {
+		if p == partition {
+			return
+		}
+	}
+	nm.partitions = append(nm.partitions, partition)
+}
+
+// parseGPUs sets the GPU and MPS metrics of a node from its configured and used GRES
+func (nm *NodeMetrics) parseGPUs(name, total, used string) {
+	// total = "gpu:a100:8" or "(null)" if no GPUs, MPS is listed
+	//         along the GPUs, e.g. "gpu:a100:4,mps:a100:400"
+	// used  = "gpu:a100:6(IDX:0,2-6)" - multiple, non-contiguous
+	//         "gpu:a100:6(IDX:0,2-3,6)" - multiple, non-contiguous
+	//         "gpu:a100:8(IDX:0-7)" - multiple, contiguous
+	//         "gpu:ada6000:1(IDX:0)" - single
+	//         "gpu:k80:0(IDX:N/A)" - none
+	//         "gpu:a100:2(IDX:0-1),gpu:a100:2(IDX:4-5)" - one group per socket
+	//         "gpu:a100:2(IDX:0-1),gpu:v100:1(IDX:4)" - several types
+	// Every group of the lists is read, the groups of a type are summed into
+	// one entry per type and every index is set in the entry it belongs to
+	for _, gres := range ParseGres(total) {
+		switch gres.name {
+		case "gpu":
+			nm.hasGPU = true
+			entry := nm.gpuEntry(gres.gtype)
+			if entry == nil {
+				entry = &GPUEntry{gpuType: gres.gtype}
+				nm.gpus = append(nm.gpus, entry)
+			}
+			nm.gpuTotal += gres.count
+			entry.total += gres.count
+		case "mps":
+			if nm.mpsTotal == nil {
+				nm.mpsTotal = make(map[string]uint64)
+				nm.mpsAlloc = make(map[string]uint64)
+			}
+			nm.mpsTotal[gres.gtype] += gres.count
+		}
+	}
+	if !nm.hasGPU {
+		return
+	}
+	first := 0
+	for _, entry := range nm.gpus {
+		entry.first = first
+		entry.index = make([]int, entry.total)
+		first += int(entry.total)
+	}
+	for _, gres := range ParseGres(used) {
+		switch gres.name {
+		case "gpu":
+			nm.gpuAlloc += gres.count
+			if entry := nm.gpuEntry(gres.gtype); entry != nil {
+				entry.alloc += gres.count
+			}
+			for _, i := range ParseGresIndex(gres.index) {
+				// The IDX can exceed the configured GPUs when a node was
+				// reconfigured with fewer GPUs than the controller still has cached
+				entry := nm.gpuEntryAt(i)
+				if entry == nil {
+					log.With("node", name).Warnf("GPU index %d beyond its %d GPUs, skipped", i, nm.gpuTotal)
+					continue
+				}
+				entry.index[i-entry.first] = 1
+			}
+		case "mps":
+			if nm.mpsAlloc != nil {
+				nm.mpsAlloc[gres.gtype] += gres.count
+			}
+		}
+	}
+}
+
+// gpuEntry returns the GPUs of the given type of a node, nil if it has none
+func (nm *NodeMetrics) gpuEntry(gpuType string) *GPUEntry {
+	for _, entry := range nm.gpus {
+		if entry.gpuType == gpuType {
+			return entry
+		}
+	}
+	return nil
+}
+
+// gpuEntryAt returns the GPU type which the given index of the node belongs to,
+// nil for an index outside of the GPUs of the node
+func (nm *NodeMetrics) gpuEntryAt(i int) *GPUEntry {
+	if i < 0 {
+		return nil
+	}
+	for _, entry := range nm.gpus {
+		if i < entry.first+len(entry.index) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// Memory in the name of a MIG profile, e.g. "5gb" of "a100_1g.5gb"
+var migProfileMemory = regexp.MustCompile(`\d+g\.(\d+)gb$`)
+
+// ParseMIGProfiles takes a list of MIG profiles with their memory such as
+// "a100_1g.5gb=4864M,a100_3g.20gb=20G" and returns the megabytes per profile
+func ParseMIGProfiles(list string) (map[string]uint64, error) {
+	profiles := make(map[string]uint64)
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid MIG profile %q, expected profile=memory", item)
+		}
+		mem, err := ParseMemory(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid memory of MIG profile %q: %v", kv[0], err)
+		}
+		profiles[kv[0]] = mem
+	}
+	return profiles, nil
+}
+
+// MIGProfileMemory returns the megabytes of a MIG profile, from the
+// configured profiles or else the memory in the name of the profile
+func MIGProfileMemory(profile string, profiles map[string]uint64) (uint64, bool) {
+	if mem, ok := profiles[profile]; ok {
+		return mem, true
+	}
+	if match := migProfileMemory.FindStringSubmatch(profile); match != nil {
+		mem, err := ParseMemory(match[1] + "G")
+		return mem, err == nil
+	}
+	return 0, false
+}
+
+// MIGMemAlloc returns the allocated GPU memory of a node in megabytes, summed
+// over its allocated MIG profiles. It is false for nodes without MIG profiles.
+func (nm *NodeMetrics) MIGMemAlloc(profiles map[string]uint64) (uint64, bool) {
+	mig := false
+	var alloc uint64
+	for _, entry := range nm.gpus {
+		if mem, ok := MIGProfileMemory(entry.gpuType, profiles); ok {
+			mig = true
+			alloc += entry.alloc * mem
+		}
+	}
+	return alloc, mig
+}
+
+// Memory units used by Slurm, relative to megabytes
+var memoryUnits = map[string]float64{
+	"K": 1.0 / 1024,
+	"M": 1,
+	"G": 1024,
+	"T": 1024 * 1024,
+	"P": 1024 * 1024 * 1024,
+}
+
+// ParseMemory converts a Slurm memory value such as "64000", "64000M" or
+// "64G" into megabytes, plain numbers are already in megabytes
+func ParseMemory(value string) (uint64, error) {
+	value = strings.TrimSpace(value)
+	factor := 1.0
+	if len(value) > 0 {
+		if f, ok := memoryUnits[strings.ToUpper(value[len(value)-1:])]; ok {
+			factor = f
+			value = value[:len(value)-1]
+		}
+	}
+	mem, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(mem * factor), nil
+}
+
+// Bytes per Slurm megabyte, Slurm counts in MiB but some tools expect MB
+var memUnitBytes = map[string]float64{
+	"MiB": 1 << 20,
+	"MB":  1e6,
+}
+
+// MemToBytes converts Slurm megabytes into bytes, with the factor of the given unit
+func MemToBytes(mem uint64, unit string) float64 {
+	return float64(mem) * memUnitBytes[unit]
+}
+
+// ParseGresIndex expands a GRES index list such as "0,2-6" into the
+// single indices, "N/A" stands for no index at all
+func ParseGresIndex(index_list string) []int {
+	indices := []int{}
+	if index_list == "N/A" || index_list == "" {
+		return indices
+	}
+	for _, part := range strings.Split(index_list, ",") {
+		if strings.Contains(part, "-") {
+			// Range
+			bounds := strings.Split(part, "-")
+			start, _ := strconv.Atoi(bounds[0])
+			end, _ := strconv.Atoi(bounds[1])
+			for i := start; i <= end; i++ {
+				indices = append(indices, i)
+			}
+		} else {
+			// Single Digit
+			num, _ := strconv.Atoi(part)
+			indices = append(indices, num)
+		}
+	}
+	return indices
+}
+
+var gresIndexRe = regexp.MustCompile(`IDX:([^)]*)`)
+
+// ParseGresDrain takes the details of the nodes from scontrol
+// It returns the indices of the drained GPUs per node, from the GresDrain
+// field, e.g. "gpu:a100:1(IDX:2)"
+func ParseGresDrain(scontrolNodes map[string]map[string]string) map[string][]int {
+	drained := make(map[string][]int)
+	for name, node := range scontrolNodes {
+		for _, match := range gresIndexRe.FindAllStringSubmatch(node["GresDrain"], -1) {
+			drained[name] = append(drained[name], ParseGresIndex(match[1])...)
+		}
+	}
+	return drained
+}
+
+// ParseNodeTRES takes the scontrol details of the nodes and the name of a
+// TRES field, CfgTRES or AllocTRES, and returns the TRES of every node
+func ParseNodeTRES(scontrolNodes map[string]map[string]string, field string) map[string]map[string]float64 {
+	tres := make(map[string]map[string]float64)
+	for node, record := range scontrolNodes {
+		if value, ok := record[field]; ok {
+			tres[node] = ParseTRES(value)
+		}
+	}
+	return tres
+}
+
+// PartitionBilling sums the allocated billing TRES of the nodes per partition,
+// a node shared by several partitions counts towards each of them
+func PartitionBilling(scontrolNodes map[string]map[string]string, alloc map[string]map[string]float64) map[string]float64 {
+	billing := make(map[string]float64)
+	for node, record := range scontrolNodes {
+		partitions, ok := record["Partitions"]
+		if !ok || partitions == "(null)" {
+			continue
+		}
+		for _, partition := range strings.Split(partitions, ",") {
+			billing[partition] += alloc[node]["billing"]
+		}
+	}
+	return billing
+}
+
+// Physical and allocatable resources of the whole cluster
+type ClusterCapacity struct {
+	cpuTotal       float64
+	cpuAllocatable float64
+	memTotal       float64
+	memAllocatable float64
+	gpuTotal       map[string]float64
+	gpuAllocatable map[string]float64
+	// Cloud nodes in a power saving transition
+	poweringUp  float64
+	poweredDown float64
+}
+
+// NodeClusterCapacity sums the resources of all nodes, the allocatable
+// resources only those of the nodes which are Schedulable
+func NodeClusterCapacity(nodes map[string]*NodeMetrics) *ClusterCapacity {
+	cc := &ClusterCapacity{
+		gpuTotal:       make(map[string]float64),
+		gpuAllocatable: make(map[string]float64),
+	}
+	for _, node := range nodes {
+		schedulable := node.Schedulable()
+		switch NodePowerState(node.nodeStatus) {
+		case "powering_up":
+			cc.poweringUp++
+		case "powered_down":
+			cc.poweredDown++
+		}
+		cc.cpuTotal += float64(node.cpuTotal)
+		cc.memTotal += float64(node.memTotal)
+		if schedulable {
+			cc.cpuAllocatable += float64(node.cpuTotal)
+			cc.memAllocatable += float64(node.memTotal)
+		}
+		for _, entry := range node.gpus {
+			cc.gpuTotal[entry.gpuType] += float64(entry.total)
+			if schedulable {
+				cc.gpuAllocatable[entry.gpuType] += float64(entry.total)
+			}
+		}
+	}
+	return cc
+}
+
+// NodePartitionsData executes sinfo to list the partitions of the nodes, one line per node and partition
+func NodePartitionsData() []byte {
+	return CollectorData("node", "sinfo", "-h", "-N", "-O", "NodeList:64,Partition:64")
+}
+
+// ParseNodePartitions takes the output of sinfo with nodes and partitions
+// It returns the partitions of every node, without the "*" of the default partition
+func ParseNodePartitions(input []byte) map[string][]string {
+	partitions := make(map[string][]string)
+	for _, line := range strings.Split(string(input), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		partitions[fields[0]] = append(partitions[fields[0]], strings.TrimSuffix(fields[1], "*"))
+	}
+	return partitions
+}
+
+// PartitionGPUs sums the allocated and idle GPUs per partition and GPU type,
+// a node shared by several partitions counts towards each of them
+func PartitionGPUs(nodes map[string]*NodeMetrics, partitions map[string][]string) (map[string]map[string]float64, map[string]map[string]float64) {
+	alloc := make(map[string]map[string]float64)
+	idle := make(map[string]map[string]float64)
+	for name, node := range nodes {
+		if !node.hasGPU {
+			continue
+		}
+		for _, partition := range partitions[name] {
+			if _, ok := alloc[partition]; !ok {
+				alloc[partition] = make(map[string]float64)
+				idle[partition] = make(map[string]float64)
+			}
+			for _, entry := range node.gpus {
+				alloc[partition][entry.gpuType] += float64(entry.alloc)
+				idle[partition][entry.gpuType] += float64(entry.total - min(entry.alloc, entry.total))
+			}
+		}
+	}
+	return alloc, idle
+}
+
+// PartitionGPUCapacity sums the configured GPUs per partition and GPU type,
+// from the Gres of the nodes whatever their state, down nodes included
+func PartitionGPUCapacity(nodes map[string]*NodeMetrics, partitions map[string][]string) map[string]map[string]float64 {
+	capacity := make(map[string]map[string]float64)
+	for name, node := range nodes {
+		for _, partition := range partitions[name] {
+			for _, entry := range node.gpus {
+				if _, ok := capacity[partition]; !ok {
+					capacity[partition] = make(map[string]float64)
+				}
+				capacity[partition][entry.gpuType] += float64(entry.total)
+			}
+		}
+	}
+	return capacity
+}
+
+// PartitionGPUTypes counts the distinct GPU types configured per partition,
+// from the capacity of PartitionGPUCapacity
+func PartitionGPUTypes(capacity map[string]map[string]float64) map[string]float64 {
+	types := make(map[string]float64, len(capacity))
+	for partition, gpus := range capacity {
+		types[partition] = float64(len(gpus))
+	}
+	return types
+}
+
+// comparableNodeState reduces a node state to what both sinfo and scontrol
+// report the same way, e.g. sinfo "drained" is "IDLE+DRAIN" for scontrol
+func comparableNodeState(status string) string {
+	base, flags := SplitNodeState(status)
+	switch {
+	case strings.HasPrefix(base, "drain") || strings.Contains(flags, "drain"):
+		return "drain"
+	case strings.Contains(flags, "completing"):
+		return "completing"
+	}
+	return base
+}
+
+// CompareNodeSources cross-checks the nodes reported by sinfo against the
+// details from scontrol. It returns the fields on which they disagree, per node.
+func CompareNodeSources(nodes map[string]*NodeMetrics, scontrolNodes map[string]map[string]string) map[string][]string {
+	mismatches := make(map[string][]string)
+	for name, node := range nodes {
+		details, ok := scontrolNodes[name]
+		if !ok {
+			continue
+		}
+		if comparableNodeState(node.nodeStatus) != comparableNodeState(details["State"]) {
+			mismatches[name] = append(mismatches[name], "state")
+		}
+		if mem, err := ParseMemory(details["RealMemory"]); err == nil && mem != node.memTotal {
+			mismatches[name] = append(mismatches[name], "memory")
+		}
+		if cpus, err := strconv.ParseUint(details["CPUTot"], 10, 64); err == nil && cpus != node.cpuTotal {
+			mismatches[name] = append(mismatches[name], "cpus")
+		}
+	}
+	return mismatches
+}
+
+// Longest features label of slurm_node_info, to avoid oversized series
+const maxFeaturesLabel = 128
+
+// FeaturesLabel joins the features of a node sorted into a single label value.
+// Characters other than letters, digits and "_.:-" are dropped, features which
+// do not fit into maxFeaturesLabel are left out.
+func FeaturesLabel(features string) string {
+	var sanitized []string
+	for _, feature := range strings.Split(features, ",") {
+		feature = strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_.:-", r) {
+				return r
+			}
+			return -1
+		}, feature)
+		if feature != "" {
+			sanitized = append(sanitized, feature)
+		}
+	}
+	sort.Strings(sanitized)
+	label := ""
+	for _, feature := range sanitized {
+		if len(label)+len(feature)+1 > maxFeaturesLabel {
+			break
+		}
+		if label != "" {
+			label += ","
+		}
+		label += feature
+	}
+	return label
+}
+
+// NodeDataArgs returns the arguments of the sinfo command, optionally
+// limited to a Slurm nodelist such as "node[01-04]" and to a partition
+func NodeDataArgs(nodelist, partition string) []string {
+	args := []string{"-h", "-N", "-O", "NodeList,AllocMem,Memory,CPUsState,StateLong,Gres,GresUsed:.,CPUsLoad,Sockets,Cores,Threads,Features:128,FreeMem,Partition:64,FeaturesAct:128"}
+	return append(args, nodeLimitArgs(nodelist, partition)...)
+}
+
+// nodeLimitArgs returns the arguments of sinfo limiting it to the nodes of
+// a nodelist and of a partition, none for the whole cluster
+func nodeLimitArgs(nodelist, partition string) []string {
+	var args []string
+	if nodelist != "" {
+		args = append(args, "-n", nodelist)
+	}
+	if partition != "" {
+		args = append(args, "-p", partition)
+	}
+	return args
+}
+
+// NodeJSONData executes sinfo --json, limited like NodeData. The columns of
+// -O do not apply, sinfo lists every node with all of its fields.
+func NodeJSONData() ([]byte, error) {
+	args := append([]string{"--json"}, nodeLimitArgs(*nodeList, *slurmPartition)...)
+	return RunCommand("node", "sinfo", args...)
+}
+
 // NodeData executes the sinfo command to get data for each node
 // It returns the output of the sinfo command
-func NodeData() []byte {
-	cmd := exec.Command("sinfo", "-h", "-N", "-O", "NodeList,AllocMem,Memory,CPUsState,StateLong,Gres,GresUsed:.")
-	out, err := cmd.Output()
-	if err != nil {
-		log.Fatal(err)
+func NodeData() ([]byte, error) {
+	return RunCommand("node", "sinfo", NodeDataArgs(*nodeList, *slurmPartition)...)
+}
+
+// GPUFragmented reports if a node has both allocated and idle GPUs,
+// such a node can not satisfy a request for all of its GPUs
+func (nm *NodeMetrics) GPUFragmented() bool {
+	return nm.gpuAlloc > 0 && nm.gpuAlloc < nm.gpuTotal
+}
+
+// GPUFlapTracker follows the GPU allocation of every node across scrapes,
+// a node flaps when its allocation changes more often than a threshold
+// within an interval, which can indicate scheduler thrashing on that node
+type GPUFlapTracker struct {
+	mu      sync.Mutex
+	last    map[string]uint64
+	changes map[string][]time.Time
+	flaps   map[string]float64
+}
+
+func NewGPUFlapTracker() *GPUFlapTracker {
+	return &GPUFlapTracker{
+		last:    make(map[string]uint64),
+		changes: make(map[string][]time.Time),
+		flaps:   make(map[string]float64),
+	}
+}
+
+// Observe records the GPU allocation of the nodes at the given time. Every
+// change beyond threshold changes within interval counts as a flap. A node
+// missing from the nodes starts over when it comes back.
+func (t *GPUFlapTracker) Observe(nodes map[string]*NodeMetrics, now time.Time, threshold int, interval time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for name := range t.last {
+		if node, ok := nodes[name]; !ok || !node.hasGPU {
+			delete(t.last, name)
+			delete(t.changes, name)
+		}
+	}
+	for name, node := range nodes {
+		if !node.hasGPU {
+			continue
+		}
+		last, seen := t.last[name]
+		t.last[name] = node.gpuAlloc
+		if _, ok := t.flaps[name]; !ok {
+			t.flaps[name] = 0
+		}
+		if !seen || last == node.gpuAlloc {
+			continue
+		}
+		// Only keep the changes within the interval
+		changes := []time.Time{}
+		for _, change := range t.changes[name] {
+			if now.Sub(change) < interval {
+				changes = append(changes, change)
+			}
+		}
+		changes = append(changes, now)
+		t.changes[name] = changes
+		if len(changes) > threshold {
+			t.flaps[name]++
+		}
+	}
+}
+
+// Flaps returns the number of flaps per node
+func (t *GPUFlapTracker) Flaps() map[string]float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	flaps := make(map[string]float64, len(t.flaps))
+	for name, count := range t.flaps {
+		flaps[name] = count
 	}
-	return out
+	return flaps
+}
+
+// AllocatedIdleTracker accumulates per node the time it has been fully
+// allocated while doing next to no work, i.e. jobs holding the node idle
+type AllocatedIdleTracker struct {
+	mu      sync.Mutex
+	since   map[string]time.Time
+	seconds map[string]float64
+}
+
+func NewAllocatedIdleTracker() *AllocatedIdleTracker {
+	return &AllocatedIdleTracker{
+		since:   make(map[string]time.Time),
+		seconds: make(map[string]float64),
+	}
+}
+
+// AllocatedIdle reports if all CPUs of a node are allocated while its CPU
+// load per allocated CPU is below the threshold. A node without a load,
+// e.g. not responding, is never allocated but idle.
+func (nm *NodeMetrics) AllocatedIdle(threshold float64) bool {
+	if !nm.hasLoad || nm.cpuTotal == 0 || nm.cpuAlloc < nm.cpuTotal {
+		return false
+	}
+	return nm.cpuLoad/float64(nm.cpuAlloc) < threshold
+}
+
+// Observe records the nodes at the given time, the time between two scrapes
+// in which a node was allocated but idle is added to its counter. The time
+// a node is missing from the nodes is not counted.
+func (t *AllocatedIdleTracker) Observe(nodes map[string]*NodeMetrics, now time.Time, threshold float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for name := range t.since {
+		if _, ok := nodes[name]; !ok {
+			delete(t.since, name)
+		}
+	}
+	for name, node := range nodes {
+		if _, ok := t.seconds[name]; !ok {
+			t.seconds[name] = 0
+		}
+		if !node.AllocatedIdle(threshold) {
+			delete(t.since, name)
+			continue
+		}
+		if since, ok := t.since[name]; ok {
+			t.seconds[name] += now.Sub(since).Seconds()
+		}
+		t.since[name] = now
+	}
+}
+
+// Seconds returns the allocated but idle time per node
+func (t *AllocatedIdleTracker) Seconds() map[string]float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	seconds := make(map[string]float64, len(t.seconds))
+	for name, value := range t.seconds {
+		seconds[name] = value
+	}
+	return seconds
+}
+
+// DownTracker accumulates per node the time it has been down or drained,
+// i.e. unavailable for new jobs, for availability SLAs. The counters start
+// at 0 when the exporter starts.
+type DownTracker struct {
+	mu      sync.Mutex
+	since   map[string]time.Time
+	seconds map[string]float64
+}
+
+func NewDownTracker() *DownTracker {
+	return &DownTracker{
+		since:   make(map[string]time.Time),
+		seconds: make(map[string]float64),
+	}
+}
+
+// Observe records the nodes at the given time, the time between two scrapes
+// in which a node was unavailable is added to its counter. The time a
+// node is missing from the nodes is not counted.
+func (t *DownTracker) Observe(nodes map[string]*NodeMetrics, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for name := range t.since {
+		if _, ok := nodes[name]; !ok {
+			delete(t.since, name)
+		}
+	}
+	for name, node := range nodes {
+		if _, ok := t.seconds[name]; !ok {
+			t.seconds[name] = 0
+		}
+		if !node.Unavailable() {
+			delete(t.since, name)
+			continue
+		}
+		if since, ok := t.since[name]; ok {
+			t.seconds[name] += now.Sub(since).Seconds()
+		}
+		t.since[name] = now
+	}
+}
+
+// Seconds returns the down time per node
+func (t *DownTracker) Seconds() map[string]float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	seconds := make(map[string]float64, len(t.seconds))
+	for name, value := range t.seconds {
+		seconds[name] = value
+	}
+	return seconds
+}
+
+// GPUIndexCache keeps the per-index GPU allocation of the nodes and only
+// refreshes it once per interval: on large GPU fleets the per-index series
+// change on almost every scrape, while the per-node totals stay current
+type GPUIndexCache struct {
+	mu       sync.Mutex
+	interval time.Duration
+	lastRun  time.Time
+	entries  map[string][]GPUEntry
+}
+
+func NewGPUIndexCache(interval time.Duration) *GPUIndexCache {
+	return &GPUIndexCache{interval: interval}
+}
+
+// Indices returns the per-index GPU allocation of the nodes, taken from the
+// given nodes when the interval has passed since the last refresh (always with
+// an interval of 0) and from the cache otherwise
+func (c *GPUIndexCache) Indices(nodes map[string]*NodeMetrics, now time.Time) map[string][]GPUEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil || c.interval <= 0 || now.Sub(c.lastRun) >= c.interval {
+		entries := make(map[string][]GPUEntry)
+		for name, node := range nodes {
+			for _, entry := range node.gpus {
+				copied := *entry
+				copied.index = append([]int(nil), entry.index...)
+				entries[name] = append(entries[name], copied)
+			}
+		}
+		c.entries = entries
+		c.lastRun = now
+	}
+	return c.entries
 }
 
 type NodeCollector struct {
+	up           *prometheus.Desc
+	duration     *prometheus.Desc
+	phase        *prometheus.Desc
+	nodeCount    *prometheus.Desc
+	stateCount   *prometheus.Desc
+	scrapeErrors prometheus.Counter
+
+	// -status-label-mode of the CPU and memory metrics
+	statusLabelMode string
+	// -node-partition-label of the CPU and memory metrics
+	partitionLabel bool
+
+	clusterCPUTotal       *prometheus.Desc
+	clusterCPUAllocatable *prometheus.Desc
+	clusterMemTotal       *prometheus.Desc
+	clusterMemAllocatable *prometheus.Desc
+	clusterGPUTotal       *prometheus.Desc
+	clusterGPUAllocatable *prometheus.Desc
+	clusterPoweringUp     *prometheus.Desc
+	clusterPoweredDown    *prometheus.Desc
+
 	cpuAlloc *prometheus.Desc
 	cpuIdle  *prometheus.Desc
 	cpuOther *prometheus.Desc
 	cpuTotal *prometheus.Desc
 
+	cpuSchedulable *prometheus.Desc
+	cpuOvercommit  *prometheus.Desc
+	cpuLoad        *prometheus.Desc
+
+	idleAvailable *prometheus.Desc
+
+	cpuConfigMismatch *prometheus.Desc
+	coresTotal        *prometheus.Desc
+	threadsTotal      *prometheus.Desc
+
+	conflictingData *prometheus.Desc
+
+	allocatedIdle        *prometheus.Desc
+	allocatedIdleTracker *AllocatedIdleTracker
+	downSeconds          *prometheus.Desc
+	downTracker          *DownTracker
+
 	memAlloc *prometheus.Desc
 	memTotal *prometheus.Desc
 
-	gpuAlloc *prometheus.Desc
+	memFree  *prometheus.Desc
+
+	memAllocBytes *prometheus.Desc
+	memTotalBytes *prometheus.Desc
+	memFreeBytes  *prometheus.Desc
+
+	gpuAlloc      *prometheus.Desc
+	gpuTotal      *prometheus.Desc
+	gpuIndexCache *GPUIndexCache
+
+	gpuPercent *prometheus.Desc
+
+	gpuIndexMismatch *prometheus.Desc
+	gpuMIGMemAlloc   *prometheus.Desc
+
+	mpsTotal *prometheus.Desc
+	mpsAlloc *prometheus.Desc
+
+	partitionGPUAlloc    *prometheus.Desc
+	partitionGPUIdle     *prometheus.Desc
+	partitionGPUCapacity *prometheus.Desc
+	partitionGPUTypes    *prometheus.Desc
+
+	gpuFragmented *prometheus.Desc
+	gpuDrained    *prometheus.Desc
+
+	gpuAllocFlaps *prometheus.Desc
+	gpuFlaps      *GPUFlapTracker
+
+	state *prometheus.Desc
+	flags map[string]*prometheus.Desc
+
+	sourceMismatch *prometheus.Desc
+
+	nodeInfo *prometheus.Desc
+
+	tresTotal *prometheus.Desc
+	tresAlloc *prometheus.Desc
+
+	billingAlloc          *prometheus.Desc
+	partitionBillingAlloc *prometheus.Desc
+}
+
+// statusLabels returns the label values of the CPU and memory metrics of a
+// node, the status label as full state, base state without flags or left out
+func (nc *NodeCollector) statusLabels(node, status string) []string {
+	switch nc.statusLabelMode {
+	case "none":
+		return []string{node}
+	case "base":
+		base, _ := SplitNodeState(status)
+		return []string{node, base}
+	}
+	return []string{node, status}
+}
+
+// cpuMemLabels returns the label values of the CPU and memory metrics of a
+// node, with -node-partition-label once per partition of the node
+func (nc *NodeCollector) cpuMemLabels(node string, nm *NodeMetrics) [][]string {
+	labels := nc.statusLabels(node, nm.nodeStatus)
+	if !nc.partitionLabel {
+		return [][]string{labels}
+	}
+	// A node without any partition keeps its series
+	if len(nm.partitions) == 0 {
+		return [][]string{append(labels, "")}
+	}
+	sets := make([][]string, 0, len(nm.partitions))
+	for _, partition := range nm.partitions {
+		sets = append(sets, append(append([]string(nil), labels...), partition))
+	}
+	return sets
+}
+
+// NewNodeFlagDescs returns a slurm_node_flag_* description per state flag
+func NewNodeFlagDescs() map[string]*prometheus.Desc {
+	descs := make(map[string]*prometheus.Desc, len(nodeStateFlags))
+	for _, flag := range nodeStateFlags {
+		descs[flag.name] = prometheus.NewDesc("slurm_node_flag_"+flag.name, "Node with the "+flag.name+" state flag", []string{"node"}, nil)
+	}
+	return descs
 }
 
 // NewNodeCollector creates a Prometheus collector to keep all our stats in
 // It returns a set of collections for consumption
 func NewNodeCollector() *NodeCollector {
 	labels_cpu := []string{"node","status"}
+	if *statusLabelMode == "none" {
+		labels_cpu = []string{"node"}
+	}
+	if *nodePartitionLabel {
+		labels_cpu = append(labels_cpu, "partition")
+	}
 	labels_gpu := []string{"node","type","index"}
 
 	return &NodeCollector{
+		statusLabelMode: *statusLabelMode,
+		partitionLabel:  *nodePartitionLabel,
+		scrapeErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "slurm_node_scrape_errors_total",
+			Help: "Scrapes without node metrics because the node data could not be read",
+		}),
+		up:         prometheus.NewDesc("slurm_up", "Whether the node data of the last scrape could be read from Slurm", nil, nil),
+		duration:   prometheus.NewDesc("slurm_node_collect_duration_seconds", "Time spent reading and parsing the node data", nil, nil),
+		phase:      prometheus.NewDesc("slurm_node_collect_phase_duration_seconds", "Time spent reading (exec) and parsing (parse) the node data", []string{"phase"}, nil),
+		nodeCount:  prometheus.NewDesc("slurm_node_count", "Number of nodes reported by Slurm", nil, nil),
+		stateCount: prometheus.NewDesc("slurm_nodes_total", "Number of nodes per state, without the state symbols", []string{"state"}, nil),
+
+		clusterCPUTotal:       prometheus.NewDesc("slurm_cluster_cpu_total", "CPUs of all nodes", nil, nil),
+		clusterCPUAllocatable: prometheus.NewDesc("slurm_cluster_cpu_allocatable", "CPUs of all nodes which are not down or drained", nil, nil),
+		clusterMemTotal:       prometheus.NewDesc("slurm_cluster_mem_total_mb", "Memory of all nodes in megabytes", nil, nil),
+		clusterMemAllocatable: prometheus.NewDesc("slurm_cluster_mem_allocatable_mb", "Memory of all nodes which are not down or drained in megabytes", nil, nil),
+		clusterGPUTotal:       prometheus.NewDesc("slurm_cluster_gpu_total", "GPUs of all nodes", []string{"type"}, nil),
+		clusterGPUAllocatable: prometheus.NewDesc("slurm_cluster_gpu_allocatable", "GPUs of all nodes which are not down or drained", []string{"type"}, nil),
+		clusterPoweringUp:     prometheus.NewDesc("slurm_cluster_nodes_powering_up", "Nodes being powered up", nil, nil),
+		clusterPoweredDown:    prometheus.NewDesc("slurm_cluster_nodes_powered_down", "Nodes powered down by the power saving", nil, nil),
+
 		cpuAlloc: prometheus.NewDesc("slurm_node_cpu_alloc", "Allocated CPUs per node", labels_cpu, nil),
 		cpuIdle:  prometheus.NewDesc("slurm_node_cpu_idle", "Idle CPUs per node", labels_cpu, nil),
 		cpuOther: prometheus.NewDesc("slurm_node_cpu_other", "Other CPUs per node", labels_cpu, nil),
 		cpuTotal: prometheus.NewDesc("slurm_node_cpu_total", "Total CPUs per node", labels_cpu, nil),
+
+		cpuConfigMismatch: prometheus.NewDesc("slurm_node_cpu_config_mismatch", "Node with total CPUs differing from sockets*cores*threads", []string{"node"}, nil),
+		coresTotal:        prometheus.NewDesc("slurm_node_cores_total", "Physical cores per node, sockets*cores", []string{"node"}, nil),
+		threadsTotal:      prometheus.NewDesc("slurm_node_threads_total", "Hardware threads per node, sockets*cores*threads", []string{"node"}, nil),
+
+		conflictingData: prometheus.NewDesc("slurm_node_conflicting_data", "Node reported by sinfo with conflicting CPU or memory totals", []string{"node"}, nil),
+
+		allocatedIdle:        prometheus.NewDesc("slurm_node_allocated_idle_seconds", "Time a node has been fully allocated while its CPU load was near zero", []string{"node"}, nil),
+		allocatedIdleTracker: NewAllocatedIdleTracker(),
+		downSeconds:          prometheus.NewDesc("slurm_node_down_seconds_total", "Time a node has been down or drained since the exporter started", []string{"node"}, nil),
+		downTracker:          NewDownTracker(),
+		gpuIndexCache:        NewGPUIndexCache(*gpuIndexRefreshInterval),
+
+		idleAvailable: prometheus.NewDesc("slurm_node_idle_available", "Idle node which accepts jobs, not drained or down", []string{"node"}, nil),
+
+		cpuSchedulable: prometheus.NewDesc("slurm_node_cpu_schedulable", "Idle CPUs per node which can be allocated to jobs right now", []string{"node"}, nil),
+		cpuOvercommit:  prometheus.NewDesc("slurm_node_cpu_overcommit", "CPUs allocated beyond the total CPUs of an oversubscribed node", []string{"node"}, nil),
+		cpuLoad:        prometheus.NewDesc("slurm_node_cpu_load", "1-minute load average per node", []string{"node"}, nil),
 		
 		memAlloc: prometheus.NewDesc("slurm_node_mem_alloc", "Allocated memory per node", labels_cpu, nil),
 		memTotal: prometheus.NewDesc("slurm_node_mem_total", "Total memory per node", labels_cpu, nil),
+		memFree:  prometheus.NewDesc("slurm_node_mem_free", "Free memory of the operating system per node", labels_cpu, nil),
+
+		memAllocBytes: prometheus.NewDesc("slurm_node_mem_alloc_bytes", "Allocated memory per node in bytes", labels_cpu, nil),
+		memTotalBytes: prometheus.NewDesc("slurm_node_mem_total_bytes", "Total memory per node in bytes", labels_cpu, nil),
+		memFreeBytes:  prometheus.NewDesc("slurm_node_mem_free_bytes", "Free memory of the operating system per node in bytes", labels_cpu, nil),
 
 		gpuAlloc: prometheus.NewDesc("slurm_node_gpu_alloc", "Allocated GPUs per node", labels_gpu, nil),
+		gpuTotal: prometheus.NewDesc("slurm_node_gpu_total", "Total GPUs per node and type", []string{"node", "type"}, nil),
+
+		gpuPercent: prometheus.NewDesc("slurm_node_gpu_percent", "Fraction of the GPUs of a node which are allocated, between 0 and 1", []string{"node", "type"}, nil),
+
+		gpuIndexMismatch: prometheus.NewDesc("slurm_node_gpu_count_index_mismatch", "Node whose number of allocated GPU indices differs from its allocated GPUs", []string{"node"}, nil),
+		gpuMIGMemAlloc:   prometheus.NewDesc("slurm_node_gpu_mig_mem_alloc_bytes", "GPU memory of the allocated MIG profiles per node", []string{"node"}, nil),
+
+		mpsTotal: prometheus.NewDesc("slurm_node_mps_total", "Total MPS shares per node, usually 100 per GPU", []string{"node", "type"}, nil),
+		mpsAlloc: prometheus.NewDesc("slurm_node_mps_alloc", "Allocated MPS shares per node", []string{"node", "type"}, nil),
+
+		partitionGPUAlloc:    prometheus.NewDesc("slurm_partition_gpu_alloc", "Allocated GPUs per partition", []string{"partition", "type"}, nil),
+		partitionGPUIdle:     prometheus.NewDesc("slurm_partition_gpu_idle", "Idle GPUs per partition", []string{"partition", "type"}, nil),
+		partitionGPUCapacity: prometheus.NewDesc("slurm_partition_gpu_capacity", "Configured GPUs per partition, including the GPUs of down nodes", []string{"partition", "type"}, nil),
+		partitionGPUTypes:    prometheus.NewDesc("slurm_partition_gpu_types", "Distinct GPU types configured on the nodes of the partition", []string{"partition"}, nil),
+
+		gpuFragmented: prometheus.NewDesc("slurm_node_gpu_fragmented", "Node with both allocated and idle GPUs of the same type", []string{"node", "type"}, nil),
+
+		gpuDrained:    prometheus.NewDesc("slurm_node_gpu_drained", "Drained GPUs per node", []string{"node", "index"}, nil),
+
+		gpuAllocFlaps: prometheus.NewDesc("slurm_node_gpu_alloc_flaps_total", "Number of times the GPU allocation of a node changed more often than the flap threshold", []string{"node"}, nil),
+		gpuFlaps:      NewGPUFlapTracker(),
+
+		state: prometheus.NewDesc("slurm_node_state", "Base state of the node, without state flags", []string{"node", "state"}, nil),
+		flags: NewNodeFlagDescs(),
+
+		sourceMismatch: prometheus.NewDesc("slurm_node_source_mismatch", "Field of a node on which sinfo and scontrol disagree", []string{"node", "field"}, nil),
+		nodeInfo:       prometheus.NewDesc("slurm_node_info", "Node with its available and active features joined into a label each", []string{"node", "features", "active_features"}, nil),
+
+		tresTotal: prometheus.NewDesc("slurm_node_tres_total", "Configured TRES per node", []string{"node", "tres"}, nil),
+		tresAlloc: prometheus.NewDesc("slurm_node_tres_alloc", "Allocated TRES per node", []string{"node", "tres"}, nil),
+
+		billingAlloc:          prometheus.NewDesc("slurm_node_billing_alloc", "Allocated billing TRES per node", []string{"node"}, nil),
+		partitionBillingAlloc: prometheus.NewDesc("slurm_partition_billing_alloc", "Allocated billing TRES per partition", []string{"partition"}, nil),
 	}
 }
 
 // Send all metric descriptions
 func (nc *NodeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- nc.up
+	ch <- nc.duration
+	ch <- nc.phase
+	ch <- nc.nodeCount
+	ch <- nc.stateCount
+	nc.scrapeErrors.Describe(ch)
+
+	ch <- nc.clusterCPUTotal
+	ch <- nc.clusterCPUAllocatable
+	ch <- nc.clusterMemTotal
+	ch <- nc.clusterMemAllocatable
+	ch <- nc.clusterGPUTotal
+	ch <- nc.clusterGPUAllocatable
+	ch <- nc.clusterPoweringUp
+	ch <- nc.clusterPoweredDown
+
 	ch <- nc.cpuAlloc
 	ch <- nc.cpuIdle
 	ch <- nc.cpuOther
 	ch <- nc.cpuTotal
 
+	ch <- nc.cpuSchedulable
+	ch <- nc.cpuOvercommit
+	ch <- nc.cpuLoad
+
+	ch <- nc.idleAvailable
+
+	ch <- nc.cpuConfigMismatch
+	ch <- nc.coresTotal
+	ch <- nc.threadsTotal
+
+	ch <- nc.conflictingData
+
+	ch <- nc.allocatedIdle
+	ch <- nc.downSeconds
+
 	ch <- nc.memAlloc
 	ch <- nc.memTotal
+	ch <- nc.memFree
+
+	ch <- nc.memAllocBytes
+	ch <- nc.memTotalBytes
+	ch <- nc.memFreeBytes
 
 	ch <- nc.gpuAlloc
+	ch <- nc.gpuTotal
+
+	ch <- nc.gpuPercent
+
+	ch <- nc.gpuIndexMismatch
+	ch <- nc.gpuMIGMemAlloc
+
+	ch <- nc.mpsTotal
+	ch <- nc.mpsAlloc
+
+	ch <- nc.partitionGPUAlloc
+	ch <- nc.partitionGPUIdle
+	ch <- nc.partitionGPUCapacity
+	ch <- nc.partitionGPUTypes
+
+	ch <- nc.gpuFragmented
+	ch <- nc.gpuDrained
+
+	ch <- nc.gpuAllocFlaps
+
+	ch <- nc.state
+	for _, desc := range nc.flags {
+		ch <- desc
+	}
+
+	ch <- nc.sourceMismatch
+	ch <- nc.nodeInfo
+
+	ch <- nc.tresTotal
+	ch <- nc.tresAlloc
+
+	ch <- nc.billingAlloc
+	ch <- nc.partitionBillingAlloc
 }
 
 func (nc *NodeCollector) Collect(ch chan<- prometheus.Metric) {
-	nodes := NodeGetMetrics()
+	nodes, times, err := NodeGetMetricsTimed()
+	// Emitted on errors too, a timeout shows as a long exec phase
+	ch <- prometheus.MustNewConstMetric(nc.duration, prometheus.GaugeValue, (times.exec + times.parse).Seconds())
+	ch <- prometheus.MustNewConstMetric(nc.phase, prometheus.GaugeValue, times.exec.Seconds(), "exec")
+	ch <- prometheus.MustNewConstMetric(nc.phase, prometheus.GaugeValue, times.parse.Seconds(), "parse")
+	if err != nil {
+		// A controller which is briefly unreachable only fails this scrape
+		log.Errorf("Node metrics: %v", err)
+		nc.scrapeErrors.Inc()
+		nc.scrapeErrors.Collect(ch)
+		ch <- prometheus.MustNewConstMetric(nc.up, prometheus.GaugeValue, 0)
+		// With -slurm.cache-ttl the last good node metrics are still served
+		if nodes == nil {
+			return
+		}
+	} else {
+		log.With("nodes", len(nodes)).With("exec", times.exec).With("parse", times.parse).Debug("Scraped the node metrics")
+		nc.scrapeErrors.Collect(ch)
+		// Set whatever the number of nodes, an empty cluster is up too
+		ch <- prometheus.MustNewConstMetric(nc.up, prometheus.GaugeValue, 1)
+	}
+	// Always emitted, an empty cluster or a nodelist matching nothing reports 0
+	ch <- prometheus.MustNewConstMetric(nc.nodeCount, prometheus.GaugeValue, float64(len(nodes)))
+	for state, count := range NodeStateCounts(nodes) {
+		ch <- prometheus.MustNewConstMetric(nc.stateCount, prometheus.GaugeValue, count, state)
+	}
+	capacity := NodeClusterCapacity(nodes)
+	ch <- prometheus.MustNewConstMetric(nc.clusterCPUTotal, prometheus.GaugeValue, capacity.cpuTotal)
+	ch <- prometheus.MustNewConstMetric(nc.clusterCPUAllocatable, prometheus.GaugeValue, capacity.cpuAllocatable)
+	ch <- prometheus.MustNewConstMetric(nc.clusterMemTotal, prometheus.GaugeValue, capacity.memTotal)
+	ch <- prometheus.MustNewConstMetric(nc.clusterMemAllocatable, prometheus.GaugeValue, capacity.memAllocatable)
+	ch <- prometheus.MustNewConstMetric(nc.clusterPoweringUp, prometheus.GaugeValue, capacity.poweringUp)
+	ch <- prometheus.MustNewConstMetric(nc.clusterPoweredDown, prometheus.GaugeValue, capacity.poweredDown)
+	for gpuType, total := range capacity.gpuTotal {
+		ch <- prometheus.MustNewConstMetric(nc.clusterGPUTotal, prometheus.GaugeValue, total, gpuType)
+		ch <- prometheus.MustNewConstMetric(nc.clusterGPUAllocatable, prometheus.GaugeValue, capacity.gpuAllocatable[gpuType], gpuType)
+	}
+	// The last good nodes of a failed read were observed already, the
+	// trackers only count what was read from Slurm
+	if err == nil {
+		now := time.Now()
+		nc.gpuFlaps.Observe(nodes, now, *gpuFlapThreshold, *gpuFlapInterval)
+		nc.allocatedIdleTracker.Observe(nodes, now, *allocIdleLoad)
+		nc.downTracker.Observe(nodes, now)
+	}
+	for node, flaps := range nc.gpuFlaps.Flaps() {
+		if _, ok := nodes[node]; ok {
+			ch <- prometheus.MustNewConstMetric(nc.gpuAllocFlaps, prometheus.CounterValue, flaps, node)
+		}
+	}
+	for node, seconds := range nc.allocatedIdleTracker.Seconds() {
+		if _, ok := nodes[node]; ok {
+			ch <- prometheus.MustNewConstMetric(nc.allocatedIdle, prometheus.CounterValue, seconds, node)
+		}
+	}
+	for node, seconds := range nc.downTracker.Seconds() {
+		if _, ok := nodes[node]; ok {
+			ch <- prometheus.MustNewConstMetric(nc.downSeconds, prometheus.CounterValue, seconds, node)
+		}
+	}
+	gpuIndices := nc.gpuIndexCache.Indices(nodes, time.Now())
+	// Details only available from scontrol, read once per scrape
+	var scontrolNodes map[string]map[string]string
+	if *gpuDrain || *nodeSource == "cross-check" || *nodeTRES {
+		scontrolNodes = ParseScontrolNodes(ScontrolNodesData("node"))
+	}
+	if *nodeSource == "cross-check" {
+		for node, fields := range CompareNodeSources(nodes, scontrolNodes) {
+			for _, field := range fields {
+				ch <- prometheus.MustNewConstMetric(nc.sourceMismatch, prometheus.GaugeValue, 1, node, field)
+			}
+		}
+	}
+	if *gpuDrain {
+		for node, indices := range ParseGresDrain(scontrolNodes) {
+			for _, i := range indices {
+				ch <- prometheus.MustNewConstMetric(nc.gpuDrained, prometheus.GaugeValue, 1, node, strconv.Itoa(i))
+			}
+		}
+	}
+	if *nodeTRES {
+		for node, tres := range ParseNodeTRES(scontrolNodes, "CfgTRES") {
+			for name, value := range tres {
+				ch <- prometheus.MustNewConstMetric(nc.tresTotal, prometheus.GaugeValue, value, node, name)
+			}
+		}
+		alloc := ParseNodeTRES(scontrolNodes, "AllocTRES")
+		for node, tres := range alloc {
+			for name, value := range tres {
+				ch <- prometheus.MustNewConstMetric(nc.tresAlloc, prometheus.GaugeValue, value, node, name)
+			}
+			ch <- prometheus.MustNewConstMetric(nc.billingAlloc, prometheus.GaugeValue, tres["billing"], node)
+		}
+		for partition, billing := range PartitionBilling(scontrolNodes, alloc) {
+			ch <- prometheus.MustNewConstMetric(nc.partitionBillingAlloc, prometheus.GaugeValue, billing, partition)
+		}
+	}
+	if *partitionGPUs {
+		partitions := ParseNodePartitions(NodePartitionsData())
+		alloc, idle := PartitionGPUs(nodes, partitions)
+		for partition, types := range alloc {
+			for gpuType, value := range types {
+				ch <- prometheus.MustNewConstMetric(nc.partitionGPUAlloc, prometheus.GaugeValue, value, partition, gpuType)
+				ch <- prometheus.MustNewConstMetric(nc.partitionGPUIdle, prometheus.GaugeValue, idle[partition][gpuType], partition, gpuType)
+			}
+		}
+		capacity := PartitionGPUCapacity(nodes, partitions)
+		for partition, types := range capacity {
+			for gpuType, value := range types {
+				ch <- prometheus.MustNewConstMetric(nc.partitionGPUCapacity, prometheus.GaugeValue, value, partition, gpuType)
+			}
+		}
+		for partition, count := range PartitionGPUTypes(capacity) {
+			ch <- prometheus.MustNewConstMetric(nc.partitionGPUTypes, prometheus.GaugeValue, count, partition)
+		}
+	}
 	for node := range nodes {
-		ch <- prometheus.MustNewConstMetric(nc.cpuAlloc, prometheus.GaugeValue, float64(nodes[node].cpuAlloc), node, nodes[node].nodeStatus)
-		ch <- prometheus.MustNewConstMetric(nc.cpuIdle,  prometheus.GaugeValue, float64(nodes[node].cpuIdle),  node, nodes[node].nodeStatus)
-		ch <- prometheus.MustNewConstMetric(nc.cpuOther, prometheus.GaugeValue, float64(nodes[node].cpuOther), node, nodes[node].nodeStatus)
-		ch <- prometheus.MustNewConstMetric(nc.cpuTotal, prometheus.GaugeValue, float64(nodes[node].cpuTotal), node, nodes[node].nodeStatus)
+		labelSets := nc.cpuMemLabels(node, nodes[node])
+		for _, labels := range labelSets {
+			ch <- prometheus.MustNewConstMetric(nc.cpuAlloc, prometheus.GaugeValue, float64(nodes[node].cpuAlloc), labels...)
+			ch <- prometheus.MustNewConstMetric(nc.cpuIdle,  prometheus.GaugeValue, float64(nodes[node].cpuIdle),  labels...)
+			ch <- prometheus.MustNewConstMetric(nc.cpuOther, prometheus.GaugeValue, float64(nodes[node].cpuOther), labels...)
+			ch <- prometheus.MustNewConstMetric(nc.cpuTotal, prometheus.GaugeValue, float64(nodes[node].cpuTotal), labels...)
+		}
 
-		ch <- prometheus.MustNewConstMetric(nc.memAlloc, prometheus.GaugeValue, float64(nodes[node].memAlloc), node, nodes[node].nodeStatus)
-		ch <- prometheus.MustNewConstMetric(nc.memTotal, prometheus.GaugeValue, float64(nodes[node].memTotal), node, nodes[node].nodeStatus)
+		ch <- prometheus.MustNewConstMetric(nc.cpuSchedulable, prometheus.GaugeValue, float64(nodes[node].SchedulableCPUs()), node)
+		ch <- prometheus.MustNewConstMetric(nc.cpuOvercommit, prometheus.GaugeValue, float64(nodes[node].CPUOvercommit()), node)
+		// Left out for down nodes rather than looking idle
+		if nodes[node].hasLoad {
+			ch <- prometheus.MustNewConstMetric(nc.cpuLoad, prometheus.GaugeValue, nodes[node].cpuLoad, node)
+		}
+
+		if *featuresAsLabel {
+			ch <- prometheus.MustNewConstMetric(nc.nodeInfo, prometheus.GaugeValue, 1, node, FeaturesLabel(nodes[node].features), FeaturesLabel(nodes[node].activeFeatures))
+		}
+
+		idleAvailable := 0.0
+		if nodes[node].IdleAvailable() {
+			idleAvailable = 1
+		}
+		ch <- prometheus.MustNewConstMetric(nc.idleAvailable, prometheus.GaugeValue, idleAvailable, node)
+
+		mismatch := 0.0
+		if nodes[node].CPUConfigMismatch() {
+			mismatch = 1
+			log.With("node", node).Warnf("%d CPUs, expected %d sockets * %d cores * %d threads",
+				nodes[node].cpuTotal, nodes[node].sockets, nodes[node].cores, nodes[node].threads)
+		}
+		ch <- prometheus.MustNewConstMetric(nc.cpuConfigMismatch, prometheus.GaugeValue, mismatch, node)
+
+		// Only for nodes reporting their topology
+		if nodes[node].ThreadsTotal() > 0 {
+			ch <- prometheus.MustNewConstMetric(nc.coresTotal, prometheus.GaugeValue, float64(nodes[node].CoresTotal()), node)
+			ch <- prometheus.MustNewConstMetric(nc.threadsTotal, prometheus.GaugeValue, float64(nodes[node].ThreadsTotal()), node)
+		}
+
+		if nodes[node].conflicting {
+			ch <- prometheus.MustNewConstMetric(nc.conflictingData, prometheus.GaugeValue, 1, node)
+		}
+
+		for _, labels := range labelSets {
+			ch <- prometheus.MustNewConstMetric(nc.memAlloc, prometheus.GaugeValue, float64(nodes[node].memAlloc), labels...)
+			ch <- prometheus.MustNewConstMetric(nc.memTotal, prometheus.GaugeValue, float64(nodes[node].memTotal), labels...)
+			ch <- prometheus.MustNewConstMetric(nc.memFree, prometheus.GaugeValue, float64(nodes[node].memFree), labels...)
+
+			ch <- prometheus.MustNewConstMetric(nc.memAllocBytes, prometheus.GaugeValue, MemToBytes(nodes[node].memAlloc, *memUnit), labels...)
+			ch <- prometheus.MustNewConstMetric(nc.memTotalBytes, prometheus.GaugeValue, MemToBytes(nodes[node].memTotal, *memUnit), labels...)
+			ch <- prometheus.MustNewConstMetric(nc.memFreeBytes, prometheus.GaugeValue, MemToBytes(nodes[node].memFree, *memUnit), labels...)
+		}
+
+		ch <- prometheus.MustNewConstMetric(nc.state, prometheus.GaugeValue, 1, node, nodes[node].nodeState)
+		for name, desc := range nc.flags {
+			set := 0.0
+			if nodes[node].nodeFlags[name] {
+				set = 1
+			}
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, set, node)
+		}
 
 		if (nodes[node].hasGPU) {
-			for i := range nodes[node].gpuIndex {
-				ch <- prometheus.MustNewConstMetric(nc.gpuAlloc, prometheus.GaugeValue, float64(nodes[node].gpuIndex[i]), node, nodes[node].gpuType, strconv.Itoa(i))
+			for _, entry := range gpuIndices[node] {
+				for i := range entry.index {
+					if *gpuSuppressIdleIndex && entry.index[i] == 0 {
+						continue
+					}
+					ch <- prometheus.MustNewConstMetric(nc.gpuAlloc, prometheus.GaugeValue, float64(entry.index[i]), node, entry.gpuType, strconv.Itoa(entry.first+i))
+				}
+			}
+			for _, entry := range nodes[node].gpus {
+				ch <- prometheus.MustNewConstMetric(nc.gpuTotal, prometheus.GaugeValue, float64(entry.total), node, entry.gpuType)
+
+				fragmented := 0.0
+				if entry.Fragmented() {
+					fragmented = 1
+				}
+				ch <- prometheus.MustNewConstMetric(nc.gpuFragmented, prometheus.GaugeValue, fragmented, node, entry.gpuType)
+
+				ch <- prometheus.MustNewConstMetric(nc.gpuPercent, prometheus.GaugeValue, entry.Percent(), node, entry.gpuType)
+			}
+
+			indexMismatch := 0.0
+			if nodes[node].gpuIndexMismatch {
+				indexMismatch = 1
+			}
+			ch <- prometheus.MustNewConstMetric(nc.gpuIndexMismatch, prometheus.GaugeValue, indexMismatch, node)
+
+			if migAlloc, ok := nodes[node].MIGMemAlloc(migProfiles); ok {
+				ch <- prometheus.MustNewConstMetric(nc.gpuMIGMemAlloc, prometheus.GaugeValue, MemToBytes(migAlloc, *memUnit), node)
+			}
+
+			for gtype, total := range nodes[node].mpsTotal {
+				ch <- prometheus.MustNewConstMetric(nc.mpsTotal, prometheus.GaugeValue, float64(total), node, gtype)
+				ch <- prometheus.MustNewConstMetric(nc.mpsAlloc, prometheus.GaugeValue, float64(nodes[node].mpsAlloc[gtype]), node, gtype)
 			}
 		}
 	}
@@ -0,0 +1,88 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Running job on a node
+type NodeJob struct {
+	jobID string
+	user  string
+}
+
+// NodeJobsArgs returns the squeue arguments listing the running jobs with
+// their nodes, optionally limited to a partition
+func NodeJobsArgs(partition string) []string {
+	args := []string{"-a", "-h", "-t", "RUNNING", "-o", "%i|%u|%N"}
+	if partition != "" {
+		args = append(args, "-p", partition)
+	}
+	return args
+}
+
+// Execute the squeue command and return the running jobs with their nodes
+func NodeJobsData() []byte {
+	return CollectorData("node_jobs", "squeue", NodeJobsArgs(*nodeJobPartition)...)
+}
+
+// ParseNodeJobs takes the output of squeue with job ID, user and nodelist
+// It returns the running jobs of every node
+func ParseNodeJobs(input []byte) map[string][]NodeJob {
+	jobs := make(map[string][]NodeJob)
+	for _, line := range strings.Split(string(input), "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "|")
+		if len(fields) != 3 {
+			continue
+		}
+		for _, node := range ExpandHostlist(fields[2]) {
+			jobs[node] = append(jobs[node], NodeJob{jobID: fields[0], user: fields[1]})
+		}
+	}
+	return jobs
+}
+
+/*
+ * Implement the Prometheus Collector interface and feed the
+ * jobs running on every node into it.
+ * https://godoc.org/github.com/prometheus/client_golang/prometheus#Collector
+ */
+
+func NewNodeJobsCollector() *NodeJobsCollector {
+	return &NodeJobsCollector{
+		jobInfo: prometheus.NewDesc("slurm_node_job_info", "Job running on a node", []string{"node", "job_id", "user"}, nil),
+	}
+}
+
+type NodeJobsCollector struct {
+	jobInfo *prometheus.Desc
+}
+
+// Send all metric descriptions
+func (njc *NodeJobsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- njc.jobInfo
+}
+
+func (njc *NodeJobsCollector) Collect(ch chan<- prometheus.Metric) {
+	for node, jobs := range ParseNodeJobs(NodeJobsData()) {
+		for _, job := range jobs {
+			ch <- prometheus.MustNewConstMetric(njc.jobInfo, prometheus.GaugeValue, 1, node, job.jobID, job.user)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeJobs(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/squeue_node_jobs.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	jobs := ParseNodeJobs(data)
+
+	assert.Len(t, jobs, 4)
+	assert.Equal(t, []NodeJob{{jobID: "1001", user: "alice"}}, jobs["node01"])
+	assert.Equal(t, []NodeJob{{jobID: "1001", user: "alice"}, {jobID: "1002", user: "bob"}}, jobs["node03"])
+	assert.Equal(t, []NodeJob{{jobID: "1003_4", user: "carol"}}, jobs["gpu01"])
+}
+
+func TestNodeJobsArgs(t *testing.T) {
+	assert.NotContains(t, NodeJobsArgs(""), "-p")
+	args := NodeJobsArgs("gpu")
+	assert.Equal(t, []string{"-p", "gpu"}, args[len(args)-2:])
+}
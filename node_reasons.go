@@ -0,0 +1,74 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NodeReasonsData lists the nodes which are down, drained or failing with
+// the reason set by the administrator or by Slurm, e.g. "Not responding".
+// The reason contains spaces, the fields are separated with "|" instead.
+// Like the node metrics, the nodes are limited by -nodes and -slurm.partition.
+func NodeReasonsData() []byte {
+	args := append([]string{"-h", "-N", "-R", "-o", "%N|%E"}, nodeLimitArgs(*nodeList, *slurmPartition)...)
+	return CollectorData("node_reasons", "sinfo", args...)
+}
+
+// ParseNodeReasons takes the nodes and reasons listed by sinfo -R
+// It returns the reason of every node, a node listed once per partition
+// is only returned once.
+func ParseNodeReasons(input []byte) map[string]string {
+	reasons := make(map[string]string)
+	for _, line := range strings.Split(string(input), "\n") {
+		// Only the first separator, the reason may contain "|" itself
+		fields := strings.SplitN(strings.TrimRight(line, "\r"), "|", 2)
+		if len(fields) != 2 || fields[0] == "" {
+			continue
+		}
+		reasons[fields[0]] = strings.TrimSpace(fields[1])
+	}
+	return reasons
+}
+
+/*
+ * Implement the Prometheus Collector interface and feed the
+ * reasons of the down and drained nodes into it.
+ * https://godoc.org/github.com/prometheus/client_golang/prometheus#Collector
+ */
+
+func NewNodeReasonsCollector() *NodeReasonsCollector {
+	return &NodeReasonsCollector{
+		down: prometheus.NewDesc("slurm_node_down", "Down, drained or failing node with the reason of its state", []string{"node", "reason"}, nil),
+	}
+}
+
+type NodeReasonsCollector struct {
+	down *prometheus.Desc
+}
+
+// Send all metric descriptions
+func (nc *NodeReasonsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- nc.down
+}
+
+func (nc *NodeReasonsCollector) Collect(ch chan<- prometheus.Metric) {
+	for node, reason := range ParseNodeReasons(NodeReasonsData()) {
+		ch <- prometheus.MustNewConstMetric(nc.down, prometheus.GaugeValue, 1, node, reason)
+	}
+}
@@ -0,0 +1,48 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNodeReasons(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_reasons.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	reasons := ParseNodeReasons(data)
+
+	// node02 is listed for two partitions
+	assert.Len(t, reasons, 3)
+	assert.Equal(t, "Not responding", reasons["node01"])
+	assert.Equal(t, "GPU 2 XID 79", reasons["node02"])
+	assert.Equal(t, "disk full | waiting for cleanup", reasons["node03"])
+
+	assert.Empty(t, ParseNodeReasons([]byte("")))
+}
+
+func TestNodeReasonsDataLimits(t *testing.T) {
+	calls := stubCommands(t, map[string]string{"sinfo": "test_data/sinfo_reasons.txt"})
+	defer func(partition string) { *slurmPartition = partition }(*slurmPartition)
+	*slurmPartition = "gpu"
+
+	assert.Len(t, ParseNodeReasons(NodeReasonsData()), 3)
+	assert.Equal(t, []string{"-h", "-N", "-R", "-o", "%N|%E", "-p", "gpu"}, calls["sinfo"])
+}
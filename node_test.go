@@ -16,9 +16,16 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>. */
 package main
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -55,3 +62,1179 @@ func TestNodeMetrics(t *testing.T) {
 	assert.Equal(t, uint64(0), metrics["b001"].cpuOther)
 	assert.Equal(t, uint64(32), metrics["b001"].cpuTotal)
 }
+
+func TestNodeMetricsPlanned(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_mem.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	metrics := ParseNodeMetrics(data)
+
+	assert.Contains(t, metrics, "c001")
+	assert.Equal(t, "planned", metrics["c001"].nodeState)
+	assert.Equal(t, "down", metrics["b001"].nodeState)
+}
+
+func TestNodeBaseState(t *testing.T) {
+	assert.Equal(t, "planned", NodeBaseState("planned"))
+	assert.Equal(t, "idle", NodeBaseState("idle*"))
+	assert.Equal(t, "mixed", NodeBaseState("MIXED+DRAIN"))
+	assert.Equal(t, "other", NodeBaseState("foo_bar_baz"))
+}
+
+func TestNodeSchedulableCPUs(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_mem.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	metrics := ParseNodeMetrics(data)
+
+	// Idle node accepting jobs
+	assert.Equal(t, uint64(16), metrics["a052"].SchedulableCPUs())
+	// Idle but drained nodes
+	assert.Equal(t, uint64(16), metrics["c002"].cpuIdle)
+	assert.Equal(t, uint64(0), metrics["c002"].SchedulableCPUs())
+	assert.Equal(t, uint64(0), metrics["c003"].SchedulableCPUs())
+	assert.False(t, NodeSchedulable("idle*"))
+	assert.True(t, NodeSchedulable("mixed"))
+}
+
+func TestAvailableStates(t *testing.T) {
+	defer func(states map[string]bool) { availableStates = states }(availableStates)
+	nodes := map[string]*NodeMetrics{
+		"c001": {nodeStatus: "mixed", nodeState: "mixed", cpuIdle: 16},
+		"c002": {nodeStatus: "completing", nodeState: "completing", cpuIdle: 32},
+	}
+	assert.Equal(t, uint64(16), nodes["c001"].SchedulableCPUs())
+	assert.Equal(t, uint64(0), nodes["c002"].SchedulableCPUs())
+
+	// Completing nodes count as available, mixed nodes do not
+	states, err := ParseAvailableStates("idle, completing")
+	assert.NoError(t, err)
+	availableStates = states
+	assert.Equal(t, uint64(0), nodes["c001"].SchedulableCPUs())
+	assert.Equal(t, uint64(32), nodes["c002"].SchedulableCPUs())
+
+	_, err = ParseAvailableStates("idle,busy")
+	assert.Error(t, err)
+}
+
+func TestGPUFlapTracker(t *testing.T) {
+	tracker := NewGPUFlapTracker()
+	start := time.Now()
+	scrape := func(alloc uint64, at time.Duration) {
+		nodes := map[string]*NodeMetrics{
+			"gpu01": {hasGPU: true, gpuAlloc: alloc},
+			"gpu02": {hasGPU: true, gpuAlloc: 4},
+			"cpu01": {},
+		}
+		tracker.Observe(nodes, start.Add(at), 2, time.Minute)
+	}
+
+	// Two changes within the interval are tolerated
+	scrape(0, 0)
+	scrape(4, 10*time.Second)
+	scrape(0, 20*time.Second)
+	assert.Equal(t, 0.0, tracker.Flaps()["gpu01"])
+
+	// Any further change within the interval is a flap
+	scrape(4, 30*time.Second)
+	scrape(2, 40*time.Second)
+	assert.Equal(t, 2.0, tracker.Flaps()["gpu01"])
+
+	// Slow changes are not
+	scrape(4, 5*time.Minute)
+	scrape(0, 10*time.Minute)
+	assert.Equal(t, 2.0, tracker.Flaps()["gpu01"])
+
+	assert.Equal(t, 0.0, tracker.Flaps()["gpu02"])
+	assert.NotContains(t, tracker.Flaps(), "cpu01")
+
+	// A node missing from sinfo starts over, its return is no change
+	tracker.Observe(map[string]*NodeMetrics{}, start.Add(11*time.Minute), 2, time.Minute)
+	assert.Empty(t, tracker.last)
+	assert.Empty(t, tracker.changes)
+	scrape(4, 12*time.Minute)
+	assert.Equal(t, 2.0, tracker.Flaps()["gpu01"])
+	assert.Empty(t, tracker.changes["gpu01"])
+}
+
+func TestNodeDataArgs(t *testing.T) {
+	args := NodeDataArgs("", "")
+	assert.NotContains(t, args, "-n")
+	assert.NotContains(t, args, "-p")
+
+	args = NodeDataArgs("node[01-04],gpu01", "")
+	assert.Equal(t, []string{"-n", "node[01-04],gpu01"}, args[len(args)-2:])
+
+	args = NodeDataArgs("", "gpu")
+	assert.Equal(t, []string{"-p", "gpu"}, args[len(args)-2:])
+
+	// Both limits select the nodes of the nodelist in the partition
+	args = NodeDataArgs("gpu[01-04]", "gpu")
+	assert.Equal(t, []string{"-n", "gpu[01-04]", "-p", "gpu"}, args[len(args)-4:])
+}
+
+func TestNodeGPUFragmented(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_mem.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	metrics := ParseNodeMetrics(data)
+
+	// 6 out of 8 GPUs allocated
+	assert.Equal(t, uint64(6), metrics["a052"].gpuAlloc)
+	assert.Equal(t, uint64(8), metrics["a052"].gpuTotal)
+	assert.True(t, metrics["a052"].GPUFragmented())
+
+	assert.False(t, (&NodeMetrics{hasGPU: true, gpuAlloc: 8, gpuTotal: 8}).GPUFragmented())
+	assert.False(t, (&NodeMetrics{hasGPU: true, gpuAlloc: 0, gpuTotal: 8}).GPUFragmented())
+}
+
+func TestParseGresIndex(t *testing.T) {
+	assert.Equal(t, []int{0, 2, 3, 4, 5, 6}, ParseGresIndex("0,2-6"))
+	assert.Equal(t, []int{0, 2, 3, 6}, ParseGresIndex("0,2-3,6"))
+	assert.Equal(t, []int{0}, ParseGresIndex("0"))
+	assert.Equal(t, []int{}, ParseGresIndex("N/A"))
+}
+
+func TestParseGresDrain(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/scontrol_nodes.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	drained := ParseGresDrain(ParseScontrolNodes(data))
+
+	assert.Equal(t, map[string][]int{"gpu01": {2}}, drained)
+}
+
+func TestNodeCPULoad(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_mem.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	metrics := ParseNodeMetrics(data)
+
+	assert.Equal(t, 0.32, metrics["c004"].cpuLoad)
+	assert.Equal(t, 15.9, metrics["c005"].cpuLoad)
+	assert.Equal(t, 0.0, metrics["b001"].cpuLoad)
+}
+
+func TestAllocatedIdleTracker(t *testing.T) {
+	tracker := NewAllocatedIdleTracker()
+	start := time.Now()
+	nodes := map[string]*NodeMetrics{
+		"idle01": {cpuAlloc: 16, cpuTotal: 16, cpuLoad: 0.1, hasLoad: true},
+		"busy01": {cpuAlloc: 16, cpuTotal: 16, cpuLoad: 15.8, hasLoad: true},
+		"free01": {cpuAlloc: 0, cpuTotal: 16, cpuLoad: 0, hasLoad: true},
+		// CPULoad of N/A, e.g. a non-responding allocated node
+		"nload01": {cpuAlloc: 16, cpuTotal: 16},
+	}
+
+	tracker.Observe(nodes, start, 0.05)
+	assert.Equal(t, 0.0, tracker.Seconds()["idle01"])
+
+	tracker.Observe(nodes, start.Add(30*time.Second), 0.05)
+	assert.Equal(t, 30.0, tracker.Seconds()["idle01"])
+	assert.Equal(t, 0.0, tracker.Seconds()["busy01"])
+	assert.Equal(t, 0.0, tracker.Seconds()["free01"])
+	assert.Equal(t, 0.0, tracker.Seconds()["nload01"])
+
+	// The node starts working, the counter stops
+	nodes["idle01"].cpuLoad = 16
+	tracker.Observe(nodes, start.Add(60*time.Second), 0.05)
+	assert.Equal(t, 30.0, tracker.Seconds()["idle01"])
+
+	// The time a node is missing from sinfo is not counted
+	nodes["idle01"].cpuLoad = 0.1
+	tracker.Observe(nodes, start.Add(90*time.Second), 0.05)
+	tracker.Observe(map[string]*NodeMetrics{}, start.Add(120*time.Second), 0.05)
+	tracker.Observe(nodes, start.Add(600*time.Second), 0.05)
+	assert.Equal(t, 30.0, tracker.Seconds()["idle01"])
+}
+
+func TestDownTracker(t *testing.T) {
+	tracker := NewDownTracker()
+	start := time.Now()
+	nodes := map[string]*NodeMetrics{
+		"down01":  {nodeStatus: "down*"},
+		"drain01": {nodeStatus: "drained"},
+		"idle01":  {nodeStatus: "idle"},
+	}
+
+	tracker.Observe(nodes, start)
+	assert.Equal(t, 0.0, tracker.Seconds()["down01"])
+
+	tracker.Observe(nodes, start.Add(60*time.Second))
+	assert.Equal(t, 60.0, tracker.Seconds()["down01"])
+	assert.Equal(t, 60.0, tracker.Seconds()["drain01"])
+	assert.Equal(t, 0.0, tracker.Seconds()["idle01"])
+
+	// The node is resumed, its counter stops and continues once it is down again
+	nodes["down01"].nodeStatus = "idle"
+	tracker.Observe(nodes, start.Add(120*time.Second))
+	nodes["down01"].nodeStatus = "down"
+	tracker.Observe(nodes, start.Add(180*time.Second))
+	tracker.Observe(nodes, start.Add(190*time.Second))
+	assert.Equal(t, 70.0, tracker.Seconds()["down01"])
+	assert.Equal(t, 190.0, tracker.Seconds()["drain01"])
+
+	// The time a node is missing from sinfo is not counted
+	delete(nodes, "drain01")
+	tracker.Observe(nodes, start.Add(200*time.Second))
+	nodes["drain01"] = &NodeMetrics{nodeStatus: "drained"}
+	tracker.Observe(nodes, start.Add(800*time.Second))
+	assert.Equal(t, 190.0, tracker.Seconds()["drain01"])
+	tracker.Observe(nodes, start.Add(810*time.Second))
+	assert.Equal(t, 200.0, tracker.Seconds()["drain01"])
+}
+
+func TestParseMemory(t *testing.T) {
+	tests := map[string]uint64{
+		"193000": 193000,
+		"64000M": 64000,
+		"64G":    65536,
+		"64g":    65536,
+		"1.5T":   1572864,
+		"2048K":  2,
+	}
+	for value, expected := range tests {
+		mem, err := ParseMemory(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, mem, value)
+	}
+	_, err := ParseMemory("N/A")
+	assert.Error(t, err)
+}
+
+func TestNodeMetricsMemoryUnits(t *testing.T) {
+	metrics := ParseNodeMetrics([]byte("a100 48G 256G 8/56/0/64 mixed (null) gpu:0\n"))
+	assert.Equal(t, uint64(49152), metrics["a100"].memAlloc)
+	assert.Equal(t, uint64(262144), metrics["a100"].memTotal)
+}
+
+func TestCompareNodeSources(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/scontrol_nodes.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	scontrolNodes := ParseScontrolNodes(data)
+	nodes := map[string]*NodeMetrics{
+		// Both sources agree
+		"gpu01": {nodeStatus: "idle", memTotal: 512000, cpuTotal: 64},
+		"gpu02": {nodeStatus: "draining", memTotal: 512000, cpuTotal: 64},
+		// Stale state and memory
+		"cpu01": {nodeStatus: "idle", memTotal: 128000, cpuTotal: 128},
+		// Unknown to scontrol
+		"cpu99": {nodeStatus: "idle", memTotal: 128000, cpuTotal: 128},
+	}
+
+	mismatches := CompareNodeSources(nodes, scontrolNodes)
+	assert.Equal(t, map[string][]string{"cpu01": {"state", "memory"}}, mismatches)
+}
+
+func TestNodeCPUConfigMismatch(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_topology.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	nodes := ParseNodeMetrics(data)
+
+	assert.Equal(t, uint64(2), nodes["d001"].sockets)
+	assert.Equal(t, uint64(16), nodes["d001"].cores)
+	assert.Equal(t, uint64(2), nodes["d001"].threads)
+	assert.False(t, nodes["d001"].CPUConfigMismatch())
+	// Booted with a quarter of its cores disabled
+	assert.True(t, nodes["d002"].CPUConfigMismatch())
+	// No topology reported
+	assert.False(t, nodes["d003"].CPUConfigMismatch())
+}
+
+func TestNodeFreeMem(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_free_mem.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	nodes := ParseNodeMetrics(data)
+
+	assert.Equal(t, uint64(101394), nodes["m001"].memFree)
+	// Unreachable node without free memory
+	assert.Equal(t, uint64(0), nodes["m002"].memFree)
+	assert.Equal(t, uint64(256000), nodes["m002"].memTotal)
+}
+
+func TestNodeCPULoadMetric(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_free_mem.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	nodes := ParseNodeMetrics(data)
+	assert.Equal(t, 12.0, nodes["m001"].cpuLoad)
+	assert.True(t, nodes["m001"].hasLoad)
+	assert.False(t, nodes["m002"].hasLoad)
+
+	// The "N/A" load of the down node is left out
+	defer func(source NodeDataSource) { nodeDataSource = source }(nodeDataSource)
+	nodeDataSource = staticNodeSource(data)
+	expected := `
+# HELP slurm_node_cpu_load 1-minute load average per node
+# TYPE slurm_node_cpu_load gauge
+slurm_node_cpu_load{node="m001"} 12
+`
+	assert.NoError(t, testutil.CollectAndCompare(NewNodeCollector(), strings.NewReader(expected), "slurm_node_cpu_load"))
+}
+
+func TestNodeCoresThreads(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_topology.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	nodes := ParseNodeMetrics(data)
+
+	// Hyperthreaded, 64 CPUs from 32 cores with 2 threads each
+	assert.Equal(t, uint64(64), nodes["d001"].cpuTotal)
+	assert.Equal(t, uint64(32), nodes["d001"].CoresTotal())
+	assert.Equal(t, uint64(64), nodes["d001"].ThreadsTotal())
+	assert.Equal(t, uint64(0), nodes["d003"].ThreadsTotal())
+}
+
+// benchmarkSinfo returns the sinfo output of a cluster with the given number
+// of nodes, each listed in two partitions like on most clusters
+func benchmarkSinfo(nodes int) []byte {
+	var b strings.Builder
+	for p := 0; p < 2; p++ {
+		for i := 0; i < nodes; i++ {
+			fmt.Fprintf(&b, "n%05d 128000 256000 32/32/0/64 mixed gpu:a100:4(S:0-1) gpu:a100:2(IDX:0-1) 12.00 2 16 2 (null) 101394\n", i)
+		}
+	}
+	return []byte(b.String())
+}
+
+func BenchmarkParseNodeMetrics(b *testing.B) {
+	data := benchmarkSinfo(5000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ParseNodeMetrics(data)
+	}
+}
+
+func TestNodeConflictingData(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_conflicting.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	nodes := ParseNodeMetrics(data)
+
+	// Identical lines of a node in several partitions
+	assert.False(t, nodes["e001"].conflicting)
+	assert.True(t, nodes["e002"].conflicting)
+	// The last line of a node wins
+	assert.Equal(t, uint64(8), nodes["e002"].cpuTotal)
+}
+
+func TestParseNodeTRES(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/scontrol_nodes.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	scontrolNodes := ParseScontrolNodes(data)
+
+	total := ParseNodeTRES(scontrolNodes, "CfgTRES")
+	assert.Equal(t, 64.0, total["gpu01"]["cpu"])
+	assert.Equal(t, 512000.0, total["gpu01"]["mem"])
+	assert.Equal(t, 4.0, total["gpu01"]["gres/gpu"])
+	assert.NotContains(t, total["cpu01"], "gres/gpu")
+
+	alloc := ParseNodeTRES(scontrolNodes, "AllocTRES")
+	assert.Empty(t, alloc["gpu01"])
+	assert.Equal(t, 32.0, alloc["gpu02"]["cpu"])
+	assert.Equal(t, 256000.0, alloc["gpu02"]["mem"])
+	assert.Equal(t, 4.0, alloc["gpu02"]["gres/gpu"])
+}
+
+func TestPartitionBilling(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/scontrol_nodes.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	scontrolNodes := ParseScontrolNodes(data)
+	alloc := ParseNodeTRES(scontrolNodes, "AllocTRES")
+
+	assert.Equal(t, 0.0, alloc["gpu01"]["billing"])
+	assert.Equal(t, 48.0, alloc["gpu02"]["billing"])
+	assert.Equal(t, map[string]float64{"gpu": 48, "debug": 48, "cpu": 128}, PartitionBilling(scontrolNodes, alloc))
+}
+
+func TestMemToBytes(t *testing.T) {
+	assert.Equal(t, 64000.0*1048576, MemToBytes(64000, "MiB"))
+	assert.Equal(t, 64000.0*1000000, MemToBytes(64000, "MB"))
+}
+
+func TestNodeMPS(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_mps.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	nodes := ParseNodeMetrics(data)
+
+	assert.Equal(t, uint64(1), nodes["f001"].gpuTotal)
+	assert.Equal(t, map[string]uint64{"a100": 100}, nodes["f001"].mpsTotal)
+	assert.Equal(t, map[string]uint64{"a100": 50}, nodes["f001"].mpsAlloc)
+
+	assert.Equal(t, uint64(2), nodes["f002"].gpuTotal)
+	assert.Equal(t, "a100", nodes["f002"].gpus[0].gpuType)
+	assert.Equal(t, map[string]uint64{"a100": 200}, nodes["f002"].mpsTotal)
+	assert.Equal(t, uint64(0), nodes["f002"].mpsAlloc["a100"])
+}
+
+func TestNodeIdleAvailable(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_mem.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	metrics := ParseNodeMetrics(data)
+
+	assert.True(t, metrics["a052"].IdleAvailable())
+	// idle+drain looks idle but does not accept jobs
+	assert.Equal(t, "idle", metrics["c003"].nodeState)
+	assert.False(t, metrics["c003"].IdleAvailable())
+	assert.False(t, metrics["c002"].IdleAvailable())
+	assert.False(t, metrics["c004"].IdleAvailable())
+}
+
+func TestPartitionGPUs(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_node_partitions.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	partitions := ParseNodePartitions(data)
+	assert.Equal(t, []string{"cpu"}, partitions["a048"])
+	assert.Equal(t, []string{"gpu", "debug"}, partitions["a052"])
+
+	nodes := map[string]*NodeMetrics{
+		"a048": {},
+		"a052": {hasGPU: true, gpuTotal: 8, gpuAlloc: 6, gpus: []*GPUEntry{{gpuType: "a100", total: 8, alloc: 6}}},
+	}
+	alloc, idle := PartitionGPUs(nodes, partitions)
+	// The GPUs of a052 count towards both of its partitions
+	assert.Equal(t, map[string]map[string]float64{"gpu": {"a100": 6}, "debug": {"a100": 6}}, alloc)
+	assert.Equal(t, map[string]map[string]float64{"gpu": {"a100": 2}, "debug": {"a100": 2}}, idle)
+}
+
+func TestPartitionGPUCapacity(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_node_partitions.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	partitions := ParseNodePartitions(data)
+
+	// Two MIG profiles configured on a052, which is down
+	nodes := ParseNodeMetrics([]byte("a048 0 256000 0/64/0/64 idle (null) gpu:0\n" +
+		"a052 0 256000 0/0/64/64 down* gpu:a100_1g.5gb:7,gpu:a100_3g.20gb:2 gpu:a100_1g.5gb:0(IDX:N/A)\n"))
+	assert.Equal(t, map[string]map[string]float64{
+		"gpu":   {"a100_1g.5gb": 7, "a100_3g.20gb": 2},
+		"debug": {"a100_1g.5gb": 7, "a100_3g.20gb": 2},
+	}, PartitionGPUCapacity(nodes, partitions))
+}
+
+func TestPartitionGPUTypes(t *testing.T) {
+	partitions := map[string][]string{
+		"g001": {"gpu", "debug"},
+		"g002": {"gpu"},
+		"c001": {"cpu"},
+	}
+	// gpu spans an a100 and a v100 node, debug only holds the a100 node
+	nodes := ParseNodeMetrics([]byte("g001 0 256000 0/64/0/64 idle gpu:a100:4 gpu:a100:0(IDX:N/A)\n" +
+		"g002 0 256000 0/64/0/64 idle gpu:v100:4 gpu:v100:0(IDX:N/A)\n" +
+		"c001 0 256000 0/64/0/64 idle (null) gpu:0\n"))
+	assert.Equal(t, map[string]float64{"gpu": 2, "debug": 1}, PartitionGPUTypes(PartitionGPUCapacity(nodes, partitions)))
+}
+
+func TestNodeGPUPercent(t *testing.T) {
+	half := &NodeMetrics{hasGPU: true, gpuTotal: 4, gpuAlloc: 2}
+	assert.Equal(t, 0.5, half.GPUPercent())
+	// More allocated than configured GPUs, e.g. during a reconfiguration
+	over := &NodeMetrics{hasGPU: true, gpuTotal: 2, gpuAlloc: 4}
+	assert.Equal(t, 1.0, over.GPUPercent())
+	assert.Equal(t, 0.0, (&NodeMetrics{}).GPUPercent())
+}
+
+// Node source returning the parsed output of sinfo without executing it
+type staticNodeSource []byte
+
+func (s staticNodeSource) Read() ([]byte, error) {
+	return s, nil
+}
+
+func (staticNodeSource) Parse(data []byte) (map[string]*NodeMetrics, error) {
+	return ParseNodeMetrics(data), nil
+}
+
+func TestNodeMetricsEmpty(t *testing.T) {
+	assert.Empty(t, ParseNodeMetrics([]byte("")))
+	assert.Empty(t, ParseNodeMetrics([]byte("\n")))
+
+	defer func(source NodeDataSource) { nodeDataSource = source }(nodeDataSource)
+	nodeDataSource = staticNodeSource("")
+	expected := `
+# HELP slurm_node_count Number of nodes reported by Slurm
+# TYPE slurm_node_count gauge
+slurm_node_count 0
+`
+	assert.NoError(t, testutil.CollectAndCompare(NewNodeCollector(), strings.NewReader(expected), "slurm_node_count"))
+}
+
+// Node source failing like an unreachable controller
+type failingNodeSource struct{}
+
+func (failingNodeSource) Read() ([]byte, error) {
+	return nil, errors.New("sinfo: timed out after 30s")
+}
+
+func (failingNodeSource) Parse(data []byte) (map[string]*NodeMetrics, error) {
+	return nil, errors.New("nothing to parse")
+}
+
+func TestNodeMetricsError(t *testing.T) {
+	defer func(source NodeDataSource) { nodeDataSource = source }(nodeDataSource)
+	nodeDataSource = failingNodeSource{}
+	collector := NewNodeCollector()
+
+	expected := `
+# HELP slurm_node_scrape_errors_total Scrapes without node metrics because the node data could not be read
+# TYPE slurm_node_scrape_errors_total counter
+slurm_node_scrape_errors_total 2
+# HELP slurm_up Whether the node data of the last scrape could be read from Slurm
+# TYPE slurm_up gauge
+slurm_up 0
+`
+	// The collect durations are reported on errors too
+	assert.Equal(t, 5, testutil.CollectAndCount(collector))
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "slurm_node_scrape_errors_total", "slurm_up"))
+
+	// The next successful scrape reports the nodes again, an empty cluster is up
+	nodeDataSource = staticNodeSource("")
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, "slurm_node_count"))
+	up := `
+# HELP slurm_up Whether the node data of the last scrape could be read from Slurm
+# TYPE slurm_up gauge
+slurm_up 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(up), "slurm_up"))
+}
+
+// slowNodeSource takes a while to read the node data from Slurm
+type slowNodeSource struct {
+	staticNodeSource
+	delay time.Duration
+}
+
+func (s slowNodeSource) Read() ([]byte, error) {
+	time.Sleep(s.delay)
+	return s.staticNodeSource.Read()
+}
+
+func TestNodeCollectDuration(t *testing.T) {
+	defer func(source NodeDataSource) { nodeDataSource = source }(nodeDataSource)
+	nodeDataSource = slowNodeSource{staticNodeSource(""), 20 * time.Millisecond}
+
+	_, times, err := NodeGetMetricsTimed()
+	assert.NoError(t, err)
+	assert.True(t, times.exec >= 20*time.Millisecond)
+	assert.True(t, times.parse < times.exec)
+
+	// A failed read has no parse phase
+	nodeDataSource = failingNodeSource{}
+	_, times, err = NodeGetMetricsTimed()
+	assert.Error(t, err)
+	assert.Equal(t, time.Duration(0), times.parse)
+
+	collector := NewNodeCollector()
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, "slurm_node_collect_duration_seconds"))
+	assert.Equal(t, 2, testutil.CollectAndCount(collector, "slurm_node_collect_phase_duration_seconds"))
+}
+
+func TestNodeCache(t *testing.T) {
+	fetches := 0
+	var fetchErr error
+	fetch := func() (map[string]*NodeMetrics, NodeCollectTimes, error) {
+		fetches++
+		if fetchErr != nil {
+			return nil, NodeCollectTimes{}, fetchErr
+		}
+		return map[string]*NodeMetrics{"cpu01": {nodeStatus: "idle"}}, NodeCollectTimes{}, nil
+	}
+	now := time.Now()
+	cache := NewNodeCache(time.Minute)
+
+	nodes, _, err := cache.Get(now, fetch)
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 1)
+	_, _, err = cache.Get(now.Add(30*time.Second), fetch)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fetches)
+
+	// A failed read serves the last good nodes with the error, until the TTL passed again
+	fetchErr = errors.New("sinfo: timed out after 30s")
+	nodes, _, err = cache.Get(now.Add(time.Minute), fetch)
+	assert.Error(t, err)
+	assert.Len(t, nodes, 1)
+	_, _, err = cache.Get(now.Add(90*time.Second), fetch)
+	assert.Error(t, err)
+	assert.Equal(t, 2, fetches)
+	fetchErr = nil
+	_, _, err = cache.Get(now.Add(2*time.Minute), fetch)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, fetches)
+
+	// Without a TTL every call reads the nodes
+	cache = NewNodeCache(0)
+	cache.Get(now, fetch)
+	cache.Get(now, fetch)
+	assert.Equal(t, 5, fetches)
+}
+
+func TestNodeCacheOverlappingScrapes(t *testing.T) {
+	var fetches int32
+	fetch := func() (map[string]*NodeMetrics, NodeCollectTimes, error) {
+		atomic.AddInt32(&fetches, 1)
+		time.Sleep(10 * time.Millisecond)
+		return map[string]*NodeMetrics{}, NodeCollectTimes{}, nil
+	}
+	cache := NewNodeCache(time.Minute)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Get(time.Now(), fetch)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), fetches)
+}
+
+func TestNodeCacheStale(t *testing.T) {
+	defer func(source NodeDataSource) { nodeDataSource = source }(nodeDataSource)
+	defer func(cache *NodeCache) { nodeCache = cache }(nodeCache)
+	nodeCache = NewNodeCache(time.Nanosecond)
+	data, err := ioutil.ReadFile("test_data/sinfo_free_mem.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	nodeDataSource = staticNodeSource(data)
+	collector := NewNodeCollector()
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, "slurm_node_count"))
+
+	// The node metrics of the last good read are kept while slurm_up reports the error
+	nodeDataSource = failingNodeSource{}
+	time.Sleep(time.Millisecond)
+	up := `
+# HELP slurm_up Whether the node data of the last scrape could be read from Slurm
+# TYPE slurm_up gauge
+slurm_up 0
+`
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(up), "slurm_up"))
+	time.Sleep(time.Millisecond)
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, "slurm_node_count"))
+
+	// The down node of the last good read is not observed again
+	assert.Equal(t, 0.0, collector.downTracker.Seconds()["m002"])
+}
+
+func TestNodeGresGroups(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_gres_groups.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	nodes := ParseNodeMetrics(data)
+
+	// Same GPU type listed once per socket
+	assert.Equal(t, uint64(8), nodes["g001"].gpuTotal)
+	assert.Equal(t, uint64(4), nodes["g001"].gpuAlloc)
+	assert.Equal(t, 1, len(nodes["g001"].gpus))
+	assert.Equal(t, []int{1, 1, 0, 0, 1, 1, 0, 0}, nodes["g001"].gpus[0].index)
+}
+
+func TestNodeClusterCapacity(t *testing.T) {
+	nodes := map[string]*NodeMetrics{
+		"cpu01": {nodeStatus: "mixed", cpuTotal: 64, memTotal: 256000},
+		"cpu02": {nodeStatus: "idle+drain", cpuTotal: 64, memTotal: 256000},
+		"gpu01": {nodeStatus: "allocated", cpuTotal: 32, memTotal: 512000, hasGPU: true, gpuTotal: 4, gpus: []*GPUEntry{{gpuType: "a100", total: 4}}},
+		"gpu02": {nodeStatus: "down*", cpuTotal: 32, memTotal: 512000, hasGPU: true, gpuTotal: 4, gpus: []*GPUEntry{{gpuType: "a100", total: 4}}},
+	}
+	cc := NodeClusterCapacity(nodes)
+
+	assert.Equal(t, 192.0, cc.cpuTotal)
+	assert.Equal(t, 96.0, cc.cpuAllocatable)
+	assert.Equal(t, 1536000.0, cc.memTotal)
+	assert.Equal(t, 768000.0, cc.memAllocatable)
+	assert.Equal(t, map[string]float64{"a100": 8}, cc.gpuTotal)
+	assert.Equal(t, map[string]float64{"a100": 4}, cc.gpuAllocatable)
+
+	// The allocatable capacity follows -available-states
+	defer func(states map[string]bool) { availableStates = states }(availableStates)
+	nodes["cpu03"] = &NodeMetrics{nodeStatus: "completing", cpuTotal: 16, memTotal: 64000}
+	assert.Equal(t, 96.0, NodeClusterCapacity(nodes).cpuAllocatable)
+	states, err := ParseAvailableStates("idle,completing")
+	assert.NoError(t, err)
+	availableStates = states
+	cc = NodeClusterCapacity(nodes)
+	assert.Equal(t, 48.0, cc.cpuAllocatable)
+	assert.Equal(t, 576000.0, cc.memAllocatable)
+	assert.Equal(t, map[string]float64{"a100": 4}, cc.gpuAllocatable)
+}
+
+func TestNodeGPUCountIndexMismatch(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_gpu_mismatch.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	nodes := ParseNodeMetrics(data)
+
+	// Four allocated GPUs but only two indices
+	assert.True(t, nodes["h001"].gpuIndexMismatch)
+	assert.False(t, nodes["h002"].gpuIndexMismatch)
+}
+
+func TestNodeGPUIndexOutOfRange(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_gpu_reconfigured.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	nodes := ParseNodeMetrics(data)
+
+	// Indices beyond the configured GPUs are skipped, in a range and single
+	assert.Equal(t, []int{0, 0, 1, 1}, nodes["r001"].gpus[0].index)
+	assert.True(t, nodes["r001"].gpuIndexMismatch)
+	assert.Equal(t, []int{0, 1}, nodes["r002"].gpus[0].index)
+	assert.True(t, nodes["r002"].gpuIndexMismatch)
+}
+
+func TestNodePowerState(t *testing.T) {
+	assert.Equal(t, "powering_up", NodePowerState("idle#"))
+	assert.Equal(t, "powered_down", NodePowerState("idle~"))
+	assert.Equal(t, "powering_down", NodePowerState("idle%"))
+	assert.Equal(t, "", NodePowerState("mixed"))
+
+	nodes := map[string]*NodeMetrics{
+		"cloud01": {nodeStatus: "idle#"},
+		"cloud02": {nodeStatus: "allocated#"},
+		"cloud03": {nodeStatus: "idle~"},
+		"cloud04": {nodeStatus: "idle%"},
+		"cloud05": {nodeStatus: "mixed"},
+	}
+	cc := NodeClusterCapacity(nodes)
+	assert.Equal(t, 2.0, cc.poweringUp)
+	assert.Equal(t, 1.0, cc.poweredDown)
+}
+
+func TestNodeFeaturesLabel(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_features.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	nodes := ParseNodeMetrics(data)
+	assert.Equal(t, "skylake,avx512,ib", nodes["c001"].features)
+	assert.Equal(t, "", nodes["c002"].features)
+
+	assert.Equal(t, "avx512,ib,skylake", FeaturesLabel(nodes["c001"].features))
+	assert.Equal(t, "", FeaturesLabel(nodes["c002"].features))
+	assert.Equal(t, "gpu_a100,nvme", FeaturesLabel("nvme,gpu_a100\",{}"))
+
+	// sinfo cuts the fields after 20 characters unless a width is set
+	assert.Contains(t, NodeDataArgs("", "")[3], ",Features:128,")
+	assert.Contains(t, NodeDataArgs("", "")[3], ",Partition:64,FeaturesAct:128")
+	assert.Equal(t, "knl,quad,flat,cache,avx512,hbm_16g", nodes["c003"].features)
+	assert.Equal(t, "avx512,cache,flat,hbm_16g,knl,quad", FeaturesLabel(nodes["c003"].features))
+
+	// The label is capped, features which do not fit are left out
+	label := FeaturesLabel(strings.Repeat("feature,", 100))
+	assert.True(t, len(label) <= maxFeaturesLabel)
+	assert.True(t, strings.HasSuffix(label, "feature"))
+}
+
+func TestNodeCPUOvercommit(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_overcommit.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	nodes := ParseNodeMetrics(data)
+
+	assert.Equal(t, uint64(32), nodes["c001"].CPUOvercommit())
+	assert.Equal(t, uint64(0), nodes["c002"].CPUOvercommit())
+}
+
+func TestParseNodeMetricsCRLF(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_mem.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	expected := ParseNodeMetrics(data)
+
+	crlf := strings.ReplaceAll(string(data), "\n", "\r\n") + "\r\n"
+	assert.Equal(t, expected, ParseNodeMetrics([]byte(crlf)))
+}
+
+func TestNodeMIGMemAlloc(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_mig.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	nodes := ParseNodeMetrics(data)
+
+	// Memory from the names of the profiles, 3 * 5G + 1 * 20G
+	alloc, ok := nodes["mig01"].MIGMemAlloc(nil)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(35*1024), alloc)
+
+	// Configured memory of a profile
+	profiles, err := ParseMIGProfiles("a100_1g.5gb=4864M")
+	assert.NoError(t, err)
+	alloc, _ = nodes["mig01"].MIGMemAlloc(profiles)
+	assert.Equal(t, uint64(3*4864+20*1024), alloc)
+
+	// No MIG profiles on the node
+	_, ok = nodes["gpu01"].MIGMemAlloc(profiles)
+	assert.False(t, ok)
+
+	_, err = ParseMIGProfiles("a100_1g.5gb")
+	assert.Error(t, err)
+}
+
+func TestNodeGPUTotal(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_mig.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	defer func(source NodeDataSource) { nodeDataSource = source }(nodeDataSource)
+	nodeDataSource = staticNodeSource(data)
+	expected := `
+# HELP slurm_node_gpu_total Total GPUs per node and type
+# TYPE slurm_node_gpu_total gauge
+slurm_node_gpu_total{node="gpu01",type="a100"} 4
+slurm_node_gpu_total{node="mig01",type="a100_1g.5gb"} 7
+slurm_node_gpu_total{node="mig01",type="a100_3g.20gb"} 2
+`
+	assert.NoError(t, testutil.CollectAndCompare(NewNodeCollector(), strings.NewReader(expected), "slurm_node_gpu_total"))
+}
+
+func TestParseNodeMetricsShortLines(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_short.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	nodes := ParseNodeMetrics(data)
+
+	// c002 misses the GresUsed column, c003 has incomplete CPU states
+	assert.Len(t, nodes, 2)
+	assert.Contains(t, nodes, "c001")
+	assert.Equal(t, uint64(16), nodes["c004"].cpuAlloc)
+}
+
+func TestNodeGPUSuppressIdleIndex(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_mig.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	defer func(source NodeDataSource) { nodeDataSource = source }(nodeDataSource)
+	nodeDataSource = staticNodeSource(data)
+	defer func(suppress bool) { *gpuSuppressIdleIndex = suppress }(*gpuSuppressIdleIndex)
+	*gpuSuppressIdleIndex = true
+
+	// Only the allocated indices, 0-1 of the 4 GPUs of gpu01, the indices
+	// of mig01 labeled with the MIG profile they belong to
+	expected := `
+# HELP slurm_node_gpu_alloc Allocated GPUs per node
+# TYPE slurm_node_gpu_alloc gauge
+slurm_node_gpu_alloc{index="0",node="gpu01",type="a100"} 1
+slurm_node_gpu_alloc{index="0",node="mig01",type="a100_1g.5gb"} 1
+slurm_node_gpu_alloc{index="1",node="gpu01",type="a100"} 1
+slurm_node_gpu_alloc{index="1",node="mig01",type="a100_1g.5gb"} 1
+slurm_node_gpu_alloc{index="2",node="mig01",type="a100_1g.5gb"} 1
+slurm_node_gpu_alloc{index="7",node="mig01",type="a100_3g.20gb"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(NewNodeCollector(), strings.NewReader(expected), "slurm_node_gpu_alloc"))
+}
+
+func TestGPUIndexCache(t *testing.T) {
+	start := time.Now()
+	gpus := &GPUEntry{gpuType: "a100", alloc: 1, total: 2, index: []int{1, 0}}
+	nodes := map[string]*NodeMetrics{
+		"gpu01": {hasGPU: true, gpus: []*GPUEntry{gpus}},
+		"cpu01": {},
+	}
+	cache := NewGPUIndexCache(5 * time.Minute)
+	assert.Equal(t, map[string][]GPUEntry{"gpu01": {*gpus}}, cache.Indices(nodes, start))
+
+	// Within the interval the cached allocation is kept
+	gpus.index = []int{1, 1}
+	assert.Equal(t, []int{1, 0}, cache.Indices(nodes, start.Add(time.Minute))["gpu01"][0].index)
+	assert.Equal(t, []int{1, 1}, cache.Indices(nodes, start.Add(5*time.Minute))["gpu01"][0].index)
+
+	// Without an interval every call refreshes
+	every := NewGPUIndexCache(0)
+	every.Indices(nodes, start)
+	gpus.index = []int{0, 0}
+	assert.Equal(t, []int{0, 0}, every.Indices(nodes, start)["gpu01"][0].index)
+}
+
+func TestNodeGPUTypes(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_gpu_types.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	nodes := ParseNodeMetrics(data)
+
+	// One entry per GPU type, the indices numbered across the types
+	gpus := nodes["dgx01"].gpus
+	assert.Equal(t, 2, len(gpus))
+	assert.Equal(t, GPUEntry{gpuType: "a100", alloc: 2, total: 4, first: 0, index: []int{1, 1, 0, 0}}, *gpus[0])
+	assert.Equal(t, GPUEntry{gpuType: "v100", alloc: 1, total: 4, first: 4, index: []int{1, 0, 0, 0}}, *gpus[1])
+	assert.Equal(t, uint64(8), nodes["dgx01"].gpuTotal)
+	assert.Equal(t, uint64(3), nodes["dgx01"].gpuAlloc)
+	assert.False(t, nodes["dgx01"].gpuIndexMismatch)
+	assert.Equal(t, 0.25, gpus[1].Percent())
+	assert.True(t, gpus[0].Fragmented())
+}
+
+func TestNodeStatusLabelMode(t *testing.T) {
+	defer func(source NodeDataSource) { nodeDataSource = source }(nodeDataSource)
+	nodeDataSource = staticNodeSource([]byte("a001 0 256000 0/64/0/64 idle* (null) gpu:0\n" +
+		"a002 0 256000 32/32/0/64 mixed+drain (null) gpu:0\n"))
+	defer func(mode string) { *statusLabelMode = mode }(*statusLabelMode)
+
+	expected := map[string]string{
+		"full": `
+# HELP slurm_node_cpu_alloc Allocated CPUs per node
+# TYPE slurm_node_cpu_alloc gauge
+slurm_node_cpu_alloc{node="a001",status="idle*"} 0
+slurm_node_cpu_alloc{node="a002",status="mixed+drain"} 32
+`,
+		"base": `
+# HELP slurm_node_cpu_alloc Allocated CPUs per node
+# TYPE slurm_node_cpu_alloc gauge
+slurm_node_cpu_alloc{node="a001",status="idle"} 0
+slurm_node_cpu_alloc{node="a002",status="mixed"} 32
+`,
+		"none": `
+# HELP slurm_node_cpu_alloc Allocated CPUs per node
+# TYPE slurm_node_cpu_alloc gauge
+slurm_node_cpu_alloc{node="a001"} 0
+slurm_node_cpu_alloc{node="a002"} 32
+`,
+	}
+	for mode, metrics := range expected {
+		*statusLabelMode = mode
+		assert.NoError(t, testutil.CollectAndCompare(NewNodeCollector(), strings.NewReader(metrics), "slurm_node_cpu_alloc"), mode)
+	}
+}
+
+func TestNodePartitionLabel(t *testing.T) {
+	data := []byte("a001 0 256000 0/64/0/64 idle (null) gpu:0 0.01 2 16 2 knl,quad,flat 250000 main* knl,quad\n" +
+		"a001 0 256000 0/64/0/64 idle (null) gpu:0 0.01 2 16 2 knl,quad,flat 250000 long knl,quad\n" +
+		"a002 32000 256000 32/32/0/64 mixed (null) gpu:0 4.00 2 16 2 (null) 200000 main (null)\n")
+	nodes := ParseNodeMetrics(data)
+	assert.Equal(t, []string{"main", "long"}, nodes["a001"].partitions)
+	assert.Equal(t, "knl,quad", nodes["a001"].activeFeatures)
+	assert.Equal(t, []string{"main"}, nodes["a002"].partitions)
+	assert.Equal(t, "", nodes["a002"].activeFeatures)
+	assert.False(t, nodes["a001"].conflicting)
+
+	// Longer than the 20 characters sinfo keeps of a field without a width
+	long := ParseNodeMetrics([]byte("a003 0 256000 0/64/0/64 idle (null) gpu:0 0.01 2 16 2 knl,quad,flat,cache 250000 gpu_a100_interactive_long knl,quad,flat,cache,hbm\n"))
+	assert.Equal(t, []string{"gpu_a100_interactive_long"}, long["a003"].partitions)
+	assert.Equal(t, "knl,quad,flat,cache,hbm", long["a003"].activeFeatures)
+
+	defer func(source NodeDataSource) { nodeDataSource = source }(nodeDataSource)
+	nodeDataSource = staticNodeSource(data)
+	defer func(label bool) { *nodePartitionLabel = label }(*nodePartitionLabel)
+
+	*nodePartitionLabel = true
+	expected := `
+# HELP slurm_node_cpu_alloc Allocated CPUs per node
+# TYPE slurm_node_cpu_alloc gauge
+slurm_node_cpu_alloc{node="a001",partition="long",status="idle"} 0
+slurm_node_cpu_alloc{node="a001",partition="main",status="idle"} 0
+slurm_node_cpu_alloc{node="a002",partition="main",status="mixed"} 32
+`
+	assert.NoError(t, testutil.CollectAndCompare(NewNodeCollector(), strings.NewReader(expected), "slurm_node_cpu_alloc"))
+
+	// Without the flag every node is exported once
+	*nodePartitionLabel = false
+	assert.Equal(t, 2, testutil.CollectAndCount(NewNodeCollector(), "slurm_node_mem_total"))
+}
+
+func TestNodeGPUTRES(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_mig.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	nodes := ParseNodeMetrics(data)
+	mig := nodes["mig01"]
+	mig.applyGPUTRES(
+		ParseTRES("cpu=64,mem=500G,gres/gpu=9,gres/gpu:a100_1g.5gb=7,gres/gpu:a100_3g.20gb=2"),
+		ParseTRES("cpu=16,mem=100G,gres/gpu=5,gres/gpu:a100_1g.5gb=4,gres/gpu:a100_3g.20gb=1"))
+	assert.Equal(t, uint64(9), mig.gpuTotal)
+	assert.Equal(t, uint64(5), mig.gpuAlloc)
+	assert.Equal(t, uint64(4), mig.gpuEntry("a100_1g.5gb").alloc)
+	assert.Equal(t, uint64(1), mig.gpuEntry("a100_3g.20gb").alloc)
+	// The Gres lists one allocated index less than the TRES
+	assert.True(t, mig.gpuIndexMismatch)
+
+	// A type missing in the Gres is added after the known ones
+	gpu := nodes["gpu01"]
+	gpu.applyGPUTRES(ParseTRES("gres/gpu=6,gres/gpu:a100=4,gres/gpu:v100=2"), ParseTRES("gres/gpu=2,gres/gpu:a100=2"))
+	assert.Equal(t, 2, len(gpu.gpus))
+	assert.Equal(t, 4, gpu.gpuEntry("v100").first)
+	assert.Equal(t, uint64(2), gpu.gpuEntry("v100").total)
+	assert.False(t, gpu.gpuIndexMismatch)
+
+	scontrol, err := ioutil.ReadFile("test_data/scontrol_nodes.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	nodes = map[string]*NodeMetrics{
+		"gpu02": {hasGPU: true, gpuTotal: 4, gpuAlloc: 2},
+		"cpu01": {},
+	}
+	ApplyGPUTRES(nodes, ParseScontrolNodes(scontrol))
+	assert.Equal(t, uint64(4), nodes["gpu02"].gpuAlloc)
+	// Nodes without GPUs in their TRES are left as they are
+	assert.False(t, nodes["cpu01"].hasGPU)
+}
+
+func TestNodeStateCounts(t *testing.T) {
+	nodes := map[string]*NodeMetrics{
+		"a001": {nodeStatus: "idle"},
+		"a002": {nodeStatus: "idle*"},
+		"a003": {nodeStatus: "mixed"},
+		"a004": {nodeStatus: "mixed+drain"},
+		"a005": {nodeStatus: "down$"},
+		"a006": {nodeStatus: "drained"},
+	}
+
+	// The symbols are stripped, the drained mixed node is counted on its own
+	assert.Equal(t, map[string]float64{
+		"idle":        2,
+		"mixed":       1,
+		"mixed+drain": 1,
+		"down":        1,
+		"drained":     1,
+	}, NodeStateCounts(nodes))
+
+	defer func(source NodeDataSource) { nodeDataSource = source }(nodeDataSource)
+	data, err := ioutil.ReadFile("test_data/sinfo_free_mem.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	nodeDataSource = staticNodeSource(data)
+	expected := `
+# HELP slurm_nodes_total Number of nodes per state, without the state symbols
+# TYPE slurm_nodes_total gauge
+slurm_nodes_total{state="down"} 1
+slurm_nodes_total{state="mixed"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(NewNodeCollector(), strings.NewReader(expected), "slurm_nodes_total"))
+}
+
+func TestNodeFlags(t *testing.T) {
+	flags := NodeFlags("down*$")
+	assert.True(t, flags["unreachable"])
+	assert.True(t, flags["maintenance"])
+	assert.False(t, flags["powered_down"])
+	assert.Len(t, flags, len(nodeStateFlags))
+
+	assert.True(t, NodeFlags("idle~")["powered_down"])
+	assert.True(t, NodeFlags("allocated#")["powering_up"])
+	assert.True(t, NodeFlags("mixed@")["reboot"])
+	// The flags in words of slurmrestd
+	rest := NodeFlags("idle+not_responding+reboot_requested")
+	assert.True(t, rest["unreachable"])
+	assert.True(t, rest["reboot"])
+	assert.False(t, rest["maintenance"])
+	// No flags at all
+	for name, set := range NodeFlags("mixed+drain") {
+		assert.False(t, set, name)
+	}
+}
+
+func TestNodeFlagMetrics(t *testing.T) {
+	defer func(source NodeDataSource) { nodeDataSource = source }(nodeDataSource)
+	nodeDataSource = staticNodeSource([]byte("a001 0 256000 0/64/0/64 idle* (null) gpu:0\n" +
+		"a002 0 256000 32/32/0/64 mixed (null) gpu:0\n"))
+
+	expected := `
+# HELP slurm_node_flag_unreachable Node with the unreachable state flag
+# TYPE slurm_node_flag_unreachable gauge
+slurm_node_flag_unreachable{node="a001"} 1
+slurm_node_flag_unreachable{node="a002"} 0
+`
+	assert.NoError(t, testutil.CollectAndCompare(NewNodeCollector(), strings.NewReader(expected), "slurm_node_flag_unreachable"))
+}
+
+func TestParseNodeMetricsGres(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		nodes    int
+		gpuTotal uint64
+		gpuAlloc uint64
+		index    []int
+		mismatch bool
+	}{
+		{"single index", "n001 0 64000 8/56/0/64 mixed gpu:a100:4 gpu:a100:1(IDX:2) 1.00",
+			1, 4, 1, []int{0, 0, 1, 0}, false},
+		{"several indices", "n001 0 64000 8/56/0/64 mixed gpu:a100:4 gpu:a100:2(IDX:0,3) 1.00",
+			1, 4, 2, []int{1, 0, 0, 1}, false},
+		{"contiguous range", "n001 0 64000 8/56/0/64 mixed gpu:a100:4 gpu:a100:3(IDX:1-3) 1.00",
+			1, 4, 3, []int{0, 1, 1, 1}, false},
+		{"non-contiguous ranges", "n001 0 64000 8/56/0/64 mixed gpu:a100:8 gpu:a100:5(IDX:0-1,4,6-7) 1.00",
+			1, 8, 5, []int{1, 1, 0, 0, 1, 0, 1, 1}, false},
+		{"no gres", "n001 0 64000 8/56/0/64 mixed (null) (null) 1.00",
+			1, 0, 0, nil, false},
+		{"no allocated index", "n001 0 64000 0/64/0/64 idle gpu:a100:4 gpu:a100:0(IDX:N/A) 0.00",
+			1, 4, 0, []int{0, 0, 0, 0}, false},
+		{"count without indices", "n001 0 64000 8/56/0/64 mixed gpu:a100:4 gpu:a100:2(IDX:0) 1.00",
+			1, 4, 2, []int{1, 0, 0, 0}, true},
+		{"missing columns", "n001 0 64000 8/56/0/64 mixed",
+			0, 0, 0, nil, false},
+		{"invalid CPU states", "n001 0 64000 8/56 mixed gpu:a100:4 gpu:a100:1(IDX:0) 1.00",
+			0, 0, 0, nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			nodes := ParseNodeMetrics([]byte(test.line + "\n"))
+			assert.Len(t, nodes, test.nodes)
+			if test.nodes == 0 {
+				return
+			}
+			nm := nodes["n001"]
+			assert.Equal(t, test.gpuTotal, nm.gpuTotal)
+			assert.Equal(t, test.gpuAlloc, nm.gpuAlloc)
+			assert.Equal(t, test.mismatch, nm.gpuIndexMismatch)
+			if test.index == nil {
+				assert.Empty(t, nm.gpus)
+				return
+			}
+			assert.Equal(t, test.index, nm.gpus[0].index)
+		})
+	}
+}
+
+func TestNodeGetMetricsStubbed(t *testing.T) {
+	defer func(source NodeDataSource) { nodeDataSource = source }(nodeDataSource)
+	nodeDataSource = ExecNodeSource{}
+	calls := stubCommands(t, map[string]string{"sinfo": "test_data/sinfo_stranded.txt"})
+
+	nodes, err := NodeGetMetrics()
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 3)
+	assert.Equal(t, uint64(3), nodes["gpu01"].gpuAlloc)
+	assert.Equal(t, NodeDataArgs(*nodeList, *slurmPartition), calls["sinfo"])
+
+	// A failing sinfo fails the node metrics instead of reporting no nodes
+	stubCommands(t, map[string]string{})
+	_, err = NodeGetMetrics()
+	assert.Error(t, err)
+}
@@ -16,15 +16,12 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>. */
 package main
 
 import (
-	"io/ioutil"
-	"os/exec"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/common/log"
 )
 
 type NodesMetrics struct {
@@ -160,59 +157,18 @@ func ParseNodesMetrics(input []byte) *NodesMetrics {
 
 // Execute the sinfo command and return its output
 func NodesData(part string) []byte {
-	cmd := exec.Command("sinfo", "-h", "-o %D|%T|%b", "-p", part, "| sort", "| uniq")
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		log.Fatal(err)
-	}
-	if err := cmd.Start(); err != nil {
-		log.Fatal(err)
-	}
-	out, _ := ioutil.ReadAll(stdout)
-	if err := cmd.Wait(); err != nil {
-		log.Fatal(err)
-	}
-	return out
-}
-
-func SlurmGetTotal() float64 {
-	cmd := exec.Command("bash", "-c", "scontrol show nodes -o | grep -c NodeName=[a-z]*[0-9]*")
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		log.Fatal(err)
-	}
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		log.Fatal(err)
-	}
-	if err := cmd.Start(); err != nil {
-		log.Fatalf("cmd.Start: %v", err)
-	}
-	out, _ := ioutil.ReadAll(stdout)
-	err_out, _ := ioutil.ReadAll(stderr)
-	if err := cmd.Wait(); err != nil {
-		log.Fatalf("cmd.Wait: %v %s %s", err, out, err_out)
-	}
-	data := strings.Split(string(out), "\n")
-	total, _ := strconv.ParseFloat(data[0], 64)
-	return total
+	return CollectorData("nodes", "sinfo", "-h", "-o %D|%T|%b", "-p", part)
 }
 
+// SlurmGetPartitions returns the partitions of the cluster, each once even
+// if sinfo lists it several times
 func SlurmGetPartitions() []string {
-	cmd := exec.Command("sinfo", "-h", "-o %R", "| sort", "| uniq")
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		log.Fatal(err)
-	}
-	if err := cmd.Start(); err != nil {
-		log.Fatal(err)
-	}
-	out, _ := ioutil.ReadAll(stdout)
-	if err := cmd.Wait(); err != nil {
-		log.Fatal(err)
+	out := CollectorData("nodes", "sinfo", "-h", "-o %R")
+	var partitions []string
+	for _, part := range strings.Split(string(out), "\n") {
+		partitions = append(partitions, strings.TrimSpace(part))
 	}
-	partitions := strings.Split(string(out), "\n")
-	return partitions
+	return RemoveDuplicates(partitions)
 }
 
 /*
@@ -238,7 +194,6 @@ func NewNodesCollector() *NodesCollector {
 		resv:    prometheus.NewDesc("slurm_nodes_resv", "Reserved nodes", labelnames, nil),
 		other:   prometheus.NewDesc("slurm_nodes_other", "Nodes reported with an unknown state", labelnames, nil),
 		planned: prometheus.NewDesc("slurm_nodes_planned", "Planned nodes", labelnames, nil),
-		total:   prometheus.NewDesc("slurm_nodes_total", "Total number of nodes", nil, nil),
 	}
 }
 
@@ -255,7 +210,6 @@ type NodesCollector struct {
 	resv    *prometheus.Desc
 	other   *prometheus.Desc
 	planned *prometheus.Desc
-	total   *prometheus.Desc
 }
 
 // Send all metric descriptions
@@ -272,7 +226,6 @@ func (nc *NodesCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- nc.resv
 	ch <- nc.other
 	ch <- nc.planned
-	ch <- nc.total
 }
 
 func SendFeatureSetMetric(ch chan<- prometheus.Metric, desc *prometheus.Desc, valueType prometheus.ValueType, featurestate map[string]float64, part string) {
@@ -302,6 +255,4 @@ func (nc *NodesCollector) Collect(ch chan<- prometheus.Metric) {
 		SendFeatureSetMetric(ch, nc.other, prometheus.GaugeValue, nm.other, part)
 		SendFeatureSetMetric(ch, nc.planned, prometheus.GaugeValue, nm.planned, part)
 	}
-	total := SlurmGetTotal()
-	ch <- prometheus.MustNewConstMetric(nc.total, prometheus.GaugeValue, total)
 }
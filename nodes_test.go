@@ -41,3 +41,12 @@ func TestNodesMetrics(t *testing.T) {
 	assert.Equal(t, 3, int(nm.planned["feature_a"]))
 	assert.Equal(t, 5, int(nm.planned["feature_b"]))
 }
+
+func TestSlurmGetPartitions(t *testing.T) {
+	calls := stubCommands(t, map[string]string{"sinfo": "test_data/sinfo_partitions_list.txt"})
+	assert.Equal(t, []string{"cpu", "gpu"}, SlurmGetPartitions())
+	assert.Equal(t, []string{"-h", "-o %R"}, calls["sinfo"])
+
+	NodesData("cpu")
+	assert.Equal(t, []string{"-h", "-o %D|%T|%b", "-p", "cpu"}, calls["sinfo"])
+}
@@ -16,44 +16,22 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>. */
 package main
 
 import (
-        "io/ioutil"
-        "os/exec"
-        "log"
         "strings"
         "strconv"
         "github.com/prometheus/client_golang/prometheus"
 )
 
 func PartitionsData() []byte {
-        cmd := exec.Command("sinfo", "-h", "-o%R,%C")
-        stdout, err := cmd.StdoutPipe()
-        if err != nil {
-                log.Fatal(err)
-        }
-        if err := cmd.Start(); err != nil {
-                log.Fatal(err)
-        }
-        out, _ := ioutil.ReadAll(stdout)
-        if err := cmd.Wait(); err != nil {
-                log.Fatal(err)
-        }
-        return out
+        return CollectorData("partitions", "sinfo", "-h", "-o%R,%C,%D")
 }
 
 func PartitionsPendingJobsData() []byte {
-        cmd := exec.Command("squeue","-a","-r","-h","-o%P","--states=PENDING")
-        stdout, err := cmd.StdoutPipe()
-        if err != nil {
-                log.Fatal(err)
-        }
-        if err := cmd.Start(); err != nil {
-                log.Fatal(err)
-        }
-        out, _ := ioutil.ReadAll(stdout)
-        if err := cmd.Wait(); err != nil {
-                log.Fatal(err)
-        }
-        return out
+        return CollectorData("partitions", "squeue","-a","-r","-h","-o%P","--states=PENDING")
+}
+
+// PartitionsReqMemData lists the partition and the requested memory of the jobs in the queue
+func PartitionsReqMemData() []byte {
+        return CollectorData("partitions", "squeue","-a","-h","-o%P|%m|%C")
 }
 
 type PartitionMetrics struct {
@@ -62,18 +40,21 @@ type PartitionMetrics struct {
         other float64
         pending float64
         total float64
+        nodes float64
 }
 
-func ParsePartitionsMetrics() map[string]*PartitionMetrics {
+// ParsePartitionMetrics takes the output of sinfo with the CPU states
+// (allocated/idle/other/total) and the number of nodes of every partition
+func ParsePartitionMetrics(input []byte) map[string]*PartitionMetrics {
         partitions := make(map[string]*PartitionMetrics)
-        lines := strings.Split(string(PartitionsData()), "\n")
+        lines := strings.Split(string(input), "\n")
         for _, line := range lines {
                 if strings.Contains(line,",") {
                         // name of a partition
                         partition := strings.Split(line,",")[0]
                         _,key := partitions[partition]
                         if !key {
-                                partitions[partition] = &PartitionMetrics{0,0,0,0,0}
+                                partitions[partition] = &PartitionMetrics{0,0,0,0,0,0}
                         }
                         states := strings.Split(line,",")[1]
                         allocated,_ := strconv.ParseFloat(strings.Split(states,"/")[0],64)
@@ -84,8 +65,16 @@ func ParsePartitionsMetrics() map[string]*PartitionMetrics {
                         partitions[partition].idle = idle
                         partitions[partition].other = other
                         partitions[partition].total = total
+                        if fields := strings.Split(line,","); len(fields) > 2 {
+                                partitions[partition].nodes,_ = strconv.ParseFloat(strings.TrimSpace(fields[2]),64)
+                        }
                 }
         }
+        return partitions
+}
+
+func ParsePartitionsMetrics() map[string]*PartitionMetrics {
+        partitions := ParsePartitionMetrics(PartitionsData())
         // get list of pending jobs by partition name
         list := strings.Split(string(PartitionsPendingJobsData()),"\n")
         for _,partition := range list {
@@ -100,12 +89,132 @@ func ParsePartitionsMetrics() map[string]*PartitionMetrics {
         return partitions
 }
 
+// PartitionDefaults takes the scontrol details of the partitions and
+// returns 1 for the default partition of the cluster (Default=YES), 0 otherwise
+func PartitionDefaults(partitions map[string]map[string]string) map[string]float64 {
+        defaults := make(map[string]float64)
+        for name, record := range partitions {
+                defaults[name] = 0
+                if record["Default"] == "YES" {
+                        defaults[name] = 1
+                }
+        }
+        return defaults
+}
+
+// PartitionStates takes the scontrol details of the partitions and returns
+// 1 for the partitions which are up (State=UP), 0 for down, drain and inactive
+func PartitionStates(partitions map[string]map[string]string) map[string]float64 {
+        states := make(map[string]float64)
+        for name, record := range partitions {
+                // The "*" sinfo appends to the default partition is no part of its name
+                name = strings.TrimSuffix(name, "*")
+                states[name] = 0
+                if record["State"] == "UP" {
+                        states[name] = 1
+                }
+        }
+        return states
+}
+
+// ReqMemMetrics aggregates the memory requested by the jobs of a partition in megabytes
+type ReqMemMetrics struct {
+        jobs uint64
+        sum uint64
+        max uint64
+}
+
+// Avg returns the average memory requested per job in megabytes
+func (rm *ReqMemMetrics) Avg() float64 {
+        if rm.jobs == 0 {
+                return 0
+        }
+        return float64(rm.sum) / float64(rm.jobs)
+}
+
+// ParsePartitionReqMem takes the partitions, requested memory and CPUs of the
+// jobs ("%P|%m|%C" of squeue) and aggregates the requests per partition. A pending
+// job submitted to several partitions ("main,gpu") counts towards each of them.
+// Older Slurm versions mark the memory per CPU ("4000Mc"), it is multiplied by
+// the CPUs of the job, and the memory per node ("4000Mn").
+func ParsePartitionReqMem(input []byte) map[string]*ReqMemMetrics {
+        partitions := make(map[string]*ReqMemMetrics)
+        for _, line := range strings.Split(string(input), "\n") {
+                fields := strings.Split(strings.TrimSpace(line), "|")
+                if len(fields) != 3 {
+                        continue
+                }
+                perCPU := strings.HasSuffix(fields[1], "c")
+                mem, err := ParseMemory(strings.TrimRight(fields[1], "cn"))
+                if err != nil {
+                        continue
+                }
+                if perCPU {
+                        cpus, _ := strconv.ParseUint(fields[2], 10, 64)
+                        mem *= cpus
+                }
+                AddReqMem(partitions, fields[0], mem)
+        }
+        return partitions
+}
+
+// AddReqMem adds the memory requested by a job to each of its partitions
+func AddReqMem(partitions map[string]*ReqMemMetrics, jobPartitions string, mem uint64) {
+        for _, partition := range strings.Split(jobPartitions, ",") {
+                rm, ok := partitions[partition]
+                if !ok {
+                        rm = &ReqMemMetrics{}
+                        partitions[partition] = rm
+                }
+                rm.jobs++
+                rm.sum += mem
+                if mem > rm.max {
+                        rm.max = mem
+                }
+        }
+}
+
+// PartitionData is the data of the partitions read for one scrape
+type PartitionData struct {
+        // CPU states, nodes and pending jobs of every partition
+        partitions map[string]*PartitionMetrics
+        // Details of every partition like scontrol show partitions
+        details map[string]map[string]string
+        // Memory requested by the jobs of every partition
+        reqMem map[string]*ReqMemMetrics
+}
+
+// PartitionDataSource provides the partitions, by executing the Slurm
+// commands or by querying the REST API of slurmrestd
+type PartitionDataSource interface {
+        // Read returns the data of the partitions, read once per scrape
+        Read() *PartitionData
+}
+
+// ExecPartitionSource executes sinfo, squeue and scontrol
+type ExecPartitionSource struct{}
+
+func (ExecPartitionSource) Read() *PartitionData {
+        return &PartitionData{
+                partitions: ParsePartitionsMetrics(),
+                details:    ParseScontrolPartitions(ScontrolPartitionsData("partitions")),
+                reqMem:     ParsePartitionReqMem(PartitionsReqMemData()),
+        }
+}
+
+var partitionDataSource PartitionDataSource = ExecPartitionSource{}
+
 type PartitionsCollector struct {
         allocated *prometheus.Desc
         idle *prometheus.Desc
         other *prometheus.Desc
         pending *prometheus.Desc
         total *prometheus.Desc
+        nodes *prometheus.Desc
+        isDefault *prometheus.Desc
+        up *prometheus.Desc
+        reqMemAvg *prometheus.Desc
+        reqMemMax *prometheus.Desc
 }
 
 func NewPartitionsCollector() *PartitionsCollector {
@@ -116,6 +225,11 @@ func NewPartitionsCollector() *PartitionsCollector {
 		other: prometheus.NewDesc("slurm_partition_cpus_other", "Other CPUs for partition", labels,nil),
 		pending: prometheus.NewDesc("slurm_partition_jobs_pending", "Pending jobs for partition", labels,nil),
 		total: prometheus.NewDesc("slurm_partition_cpus_total", "Total CPUs for partition", labels,nil),
+		nodes: prometheus.NewDesc("slurm_partition_nodes", "Nodes of the partition", labels,nil),
+		isDefault: prometheus.NewDesc("slurm_partition_is_default", "Whether the partition is the default partition of the cluster", labels,nil),
+		up: prometheus.NewDesc("slurm_partition_up", "Whether the partition is up and accepts jobs", labels,nil),
+		reqMemAvg: prometheus.NewDesc("slurm_partition_req_mem_avg_bytes", "Average memory requested by the jobs of the partition", labels,nil),
+		reqMemMax: prometheus.NewDesc("slurm_partition_req_mem_max_bytes", "Largest memory request of the jobs of the partition", labels,nil),
         }
 }
 
@@ -125,10 +239,16 @@ func (pc *PartitionsCollector) Describe(ch chan<- *prometheus.Desc) {
         ch <- pc.other
         ch <- pc.pending
         ch <- pc.total
+        ch <- pc.nodes
+        ch <- pc.isDefault
+        ch <- pc.up
+        ch <- pc.reqMemAvg
+        ch <- pc.reqMemMax
 }
 
 func (pc *PartitionsCollector) Collect(ch chan<- prometheus.Metric) {
-        pm := ParsePartitionsMetrics()
+        data := partitionDataSource.Read()
+        pm := data.partitions
         for p := range pm {
                 if pm[p].allocated > 0 {
                         ch <- prometheus.MustNewConstMetric(pc.allocated, prometheus.GaugeValue, pm[p].allocated, p)
@@ -145,5 +265,17 @@ func (pc *PartitionsCollector) Collect(ch chan<- prometheus.Metric) {
                 if pm[p].total > 0 {
                         ch <- prometheus.MustNewConstMetric(pc.total, prometheus.GaugeValue, pm[p].total, p)
                 }
+                ch <- prometheus.MustNewConstMetric(pc.nodes, prometheus.GaugeValue, pm[p].nodes, p)
+        }
+        scontrolPartitions := data.details
+        for p, value := range PartitionDefaults(scontrolPartitions) {
+                ch <- prometheus.MustNewConstMetric(pc.isDefault, prometheus.GaugeValue, value, p)
+        }
+        for p, value := range PartitionStates(scontrolPartitions) {
+                ch <- prometheus.MustNewConstMetric(pc.up, prometheus.GaugeValue, value, p)
+        }
+        for p, rm := range data.reqMem {
+                ch <- prometheus.MustNewConstMetric(pc.reqMemAvg, prometheus.GaugeValue, rm.Avg() * memUnitBytes[*memUnit], p)
+                ch <- prometheus.MustNewConstMetric(pc.reqMemMax, prometheus.GaugeValue, float64(rm.max) * memUnitBytes[*memUnit], p)
         }
 }
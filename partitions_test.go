@@ -0,0 +1,81 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionDefaults(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/scontrol_partitions.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	defaults := PartitionDefaults(ParseScontrolPartitions(data))
+
+	assert.Equal(t, map[string]float64{"debug": 0, "main": 1, "gpu": 0}, defaults)
+}
+
+func TestPartitionStates(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/scontrol_partitions.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	states := PartitionStates(ParseScontrolPartitions(data))
+
+	// The gpu partition is drained
+	assert.Equal(t, map[string]float64{"debug": 1, "main": 1, "gpu": 0}, states)
+	assert.Equal(t, map[string]float64{"main": 1}, PartitionStates(map[string]map[string]string{"main*": {"State": "UP"}}))
+}
+
+func TestParsePartitionMetrics(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sinfo_partitions.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	pm := ParsePartitionMetrics(data)
+
+	assert.Equal(t, 3, len(pm))
+	assert.Equal(t, 1200.0, pm["main"].allocated)
+	assert.Equal(t, 3480.0, pm["main"].idle)
+	assert.Equal(t, 120.0, pm["main"].other)
+	assert.Equal(t, 4800.0, pm["main"].total)
+	assert.Equal(t, 50.0, pm["main"].nodes)
+	assert.Equal(t, 2.0, pm["gpu"].nodes)
+	assert.Equal(t, 0.0, pm["debug"].allocated)
+	assert.Equal(t, 1.0, pm["debug"].nodes)
+}
+
+func TestParsePartitionReqMem(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/squeue_req_mem.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	rm := ParsePartitionReqMem(data)
+
+	assert.Equal(t, 3, len(rm))
+	// 4096 + 2000 + 6000 + 32768 megabytes over four jobs
+	assert.Equal(t, uint64(4), rm["main"].jobs)
+	assert.Equal(t, 11216.0, rm["main"].Avg())
+	assert.Equal(t, uint64(32768), rm["main"].max)
+	assert.Equal(t, 49152.0, rm["gpu"].Avg())
+	assert.Equal(t, uint64(65536), rm["gpu"].max)
+	// 4000 megabytes per CPU of 4 CPUs
+	assert.Equal(t, 16000.0, rm["debug"].Avg())
+}
@@ -0,0 +1,101 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Jobs and allocated resources per QOS
+type QOSMetrics struct {
+	jobs       map[string]map[string]float64
+	alloc_cpus map[string]float64
+}
+
+// Returns the QOS metrics
+func QOSGetMetrics() *QOSMetrics {
+	return ParseQOSMetrics(QOSData())
+}
+
+// Execute the squeue command and return the QOS, state and allocated TRES of every job
+func QOSData() []byte {
+	return CollectorData("qos", "squeue", "-a", "-h", "-O", "QOS:64,State:24,tres-alloc:512")
+}
+
+// ParseQOSMetrics takes the output of squeue with QOS, state and allocated TRES
+// It returns the number of jobs per QOS and state, and the allocated CPUs per QOS
+func ParseQOSMetrics(input []byte) *QOSMetrics {
+	qm := QOSMetrics{
+		jobs:       make(map[string]map[string]float64),
+		alloc_cpus: make(map[string]float64),
+	}
+	for _, line := range strings.Split(string(input), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		qos := fields[0]
+		state := strings.ToLower(fields[1])
+		if _, ok := qm.jobs[qos]; !ok {
+			qm.jobs[qos] = make(map[string]float64)
+			qm.alloc_cpus[qos] = 0
+		}
+		qm.jobs[qos][state]++
+		// Pending jobs have no resources allocated yet
+		if len(fields) > 2 {
+			qm.alloc_cpus[qos] += ParseTRES(fields[2])["cpu"]
+		}
+	}
+	return &qm
+}
+
+/*
+ * Implement the Prometheus Collector interface and feed the
+ * Slurm QOS metrics into it.
+ * https://godoc.org/github.com/prometheus/client_golang/prometheus#Collector
+ */
+
+func NewQOSCollector() *QOSCollector {
+	return &QOSCollector{
+		jobs:       prometheus.NewDesc("slurm_qos_jobs", "Jobs per QOS and state", []string{"qos", "state"}, nil),
+		alloc_cpus: prometheus.NewDesc("slurm_qos_alloc_cpus", "Allocated CPUs per QOS", []string{"qos"}, nil),
+	}
+}
+
+type QOSCollector struct {
+	jobs       *prometheus.Desc
+	alloc_cpus *prometheus.Desc
+}
+
+// Send all metric descriptions
+func (qc *QOSCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- qc.jobs
+	ch <- qc.alloc_cpus
+}
+
+func (qc *QOSCollector) Collect(ch chan<- prometheus.Metric) {
+	qm := QOSGetMetrics()
+	for qos, states := range qm.jobs {
+		for state, count := range states {
+			ch <- prometheus.MustNewConstMetric(qc.jobs, prometheus.GaugeValue, count, qos, state)
+		}
+	}
+	for qos, cpus := range qm.alloc_cpus {
+		ch <- prometheus.MustNewConstMetric(qc.alloc_cpus, prometheus.GaugeValue, cpus, qos)
+	}
+}
@@ -0,0 +1,146 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Execute the sacctmgr command and return the QOS with their limits, with the header
+func QOSLimitsData() []byte {
+	return CollectorData("qos_limits", "sacctmgr", "-P", "show", "qos")
+}
+
+// ParseSacctmgrTable takes the parsable output of sacctmgr (-P) with its header
+// It returns a record per line, keyed by the column names of the header, since
+// the columns differ between the Slurm versions
+func ParseSacctmgrTable(input []byte) []map[string]string {
+	var header []string
+	var records []map[string]string
+	for _, line := range strings.Split(string(input), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, "|")
+		if header == nil {
+			header = fields
+			continue
+		}
+		record := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(fields) {
+				record[name] = strings.TrimSpace(fields[i])
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// Configured limits of a QOS, unset limits are left out
+type QOSLimits struct {
+	maxCPUs *float64
+	maxJobs *float64
+	grpTRES map[string]float64
+}
+
+// firstColumn returns the value of the first of the columns the record has,
+// e.g. MaxJobsPU of newer and MaxJobs of older Slurm versions
+func firstColumn(record map[string]string, columns ...string) string {
+	for _, column := range columns {
+		if value, ok := record[column]; ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// parseLimit returns the limit, nil for an unset limit
+func parseLimit(value string) *float64 {
+	limit, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil
+	}
+	return &limit
+}
+
+// ParseQOSLimits takes the output of sacctmgr show qos
+// It returns the limits of every QOS: the CPUs per job (MaxTRES), the
+// running jobs per user (MaxJobsPU) and the TRES of all jobs (GrpTRES)
+func ParseQOSLimits(input []byte) map[string]*QOSLimits {
+	limits := make(map[string]*QOSLimits)
+	for _, record := range ParseSacctmgrTable(input) {
+		name := record["Name"]
+		if name == "" {
+			continue
+		}
+		ql := &QOSLimits{
+			maxJobs: parseLimit(firstColumn(record, "MaxJobsPU", "MaxJobsPerUser", "MaxJobs")),
+			grpTRES: ParseTRES(record["GrpTRES"]),
+		}
+		if cpus, ok := ParseTRES(record["MaxTRES"])["cpu"]; ok {
+			ql.maxCPUs = &cpus
+		} else {
+			ql.maxCPUs = parseLimit(record["MaxCPUs"])
+		}
+		limits[name] = ql
+	}
+	return limits
+}
+
+/*
+ * Implement the Prometheus Collector interface and feed the
+ * Slurm QOS limits into it.
+ * https://godoc.org/github.com/prometheus/client_golang/prometheus#Collector
+ */
+
+func NewQOSLimitsCollector() *QOSLimitsCollector {
+	return &QOSLimitsCollector{
+		maxCPUs: prometheus.NewDesc("slurm_qos_max_cpus", "CPUs a job of the QOS may allocate at most", []string{"qos"}, nil),
+		maxJobs: prometheus.NewDesc("slurm_qos_max_jobs", "Jobs a user may run at most in the QOS", []string{"qos"}, nil),
+		grpTRES: prometheus.NewDesc("slurm_qos_grp_tres", "TRES all jobs of the QOS may allocate at most", []string{"qos", "tres"}, nil),
+	}
+}
+
+type QOSLimitsCollector struct {
+	maxCPUs *prometheus.Desc
+	maxJobs *prometheus.Desc
+	grpTRES *prometheus.Desc
+}
+
+// Send all metric descriptions
+func (qc *QOSLimitsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- qc.maxCPUs
+	ch <- qc.maxJobs
+	ch <- qc.grpTRES
+}
+
+func (qc *QOSLimitsCollector) Collect(ch chan<- prometheus.Metric) {
+	for qos, ql := range ParseQOSLimits(QOSLimitsData()) {
+		if ql.maxCPUs != nil {
+			ch <- prometheus.MustNewConstMetric(qc.maxCPUs, prometheus.GaugeValue, *ql.maxCPUs, qos)
+		}
+		if ql.maxJobs != nil {
+			ch <- prometheus.MustNewConstMetric(qc.maxJobs, prometheus.GaugeValue, *ql.maxJobs, qos)
+		}
+		for tres, value := range ql.grpTRES {
+			ch <- prometheus.MustNewConstMetric(qc.grpTRES, prometheus.GaugeValue, value, qos, tres)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQOSLimits(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sacctmgr_qos.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	limits := ParseQOSLimits(data)
+	assert.Len(t, limits, 3)
+
+	// Unset limits are no limits
+	assert.Nil(t, limits["normal"].maxCPUs)
+	assert.Nil(t, limits["normal"].maxJobs)
+	assert.Empty(t, limits["normal"].grpTRES)
+
+	assert.Equal(t, 64.0, *limits["gpu"].maxCPUs)
+	assert.Equal(t, 4.0, *limits["gpu"].maxJobs)
+	assert.Equal(t, map[string]float64{"cpu": 512, "gres/gpu": 32}, limits["gpu"].grpTRES)
+	assert.Equal(t, 1.0, *limits["debug"].maxJobs)
+
+	// Older Slurm versions with other columns in another order
+	data, err = ioutil.ReadFile("test_data/sacctmgr_qos_old.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	limits = ParseQOSLimits(data)
+	assert.Equal(t, 32.0, *limits["long"].maxCPUs)
+	assert.Equal(t, 10.0, *limits["long"].maxJobs)
+}
@@ -0,0 +1,41 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQOSMetrics(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/squeue_qos.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	qm := ParseQOSMetrics(data)
+	t.Logf("%+v", qm)
+
+	assert.Equal(t, 2.0, qm.jobs["normal"]["running"])
+	assert.Equal(t, 1.0, qm.jobs["normal"]["pending"])
+	assert.Equal(t, 1.0, qm.jobs["high"]["running"])
+	assert.Equal(t, 2.0, qm.jobs["high"]["pending"])
+	assert.Equal(t, 1.0, qm.jobs["debug"]["completing"])
+	assert.Equal(t, 48.0, qm.alloc_cpus["normal"])
+	assert.Equal(t, 8.0, qm.alloc_cpus["high"])
+	assert.Equal(t, 1.0, qm.alloc_cpus["debug"])
+}
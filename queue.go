@@ -16,11 +16,9 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>. */
 package main
 
 import (
-	"io/ioutil"
-	"log"
-	"os/exec"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -51,11 +49,19 @@ type QueueMetrics struct {
 	c_timeout     NVal
 	c_preempted   NVal
 	c_node_fail   NVal
+	// Submit time of the longest waiting pending job, overall and per partition
+	oldest_pending      time.Time
+	oldest_pending_part map[string]time.Time
+	// Pending and running jobs per bucket of requested nodes and state
+	jobs_by_nodes NVal
+	// Pending jobs waiting for a dependency and the distinct chains they form
+	dependency        float64
+	dependency_chains float64
 }
 
 // Returns the scheduler metrics
 func QueueGetMetrics() *QueueMetrics {
-	return ParseQueueMetrics(QueueData())
+	return QueueJobsMetrics(queueDataSource.QueueJobs())
 }
 
 func (s *NVal) Incr(user string, part string, count float64) {
@@ -82,7 +88,119 @@ func (s *NNVal) Incr2(reason string, user string, part string, count float64) {
 	child2[part] += count
 }
 
+// NodesBucket returns the bucket of a job by its number of requested nodes
+func NodesBucket(nodes int) string {
+	switch {
+	case nodes <= 1:
+		return "1"
+	case nodes <= 4:
+		return "2-4"
+	case nodes <= 16:
+		return "5-16"
+	}
+	return "17+"
+}
+
+// DependencyJobs returns the ids of the jobs a job depends on, from the
+// dependency of squeue (%E), e.g. "afterok:123(unfulfilled),afterany:124:125"
+func DependencyJobs(dependency string) []string {
+	var jobs []string
+	for _, item := range strings.FieldsFunc(dependency, func(r rune) bool { return r == ',' || r == '?' }) {
+		// Job ids follow the type of the dependency, "singleton" has none
+		for _, id := range strings.Split(item, ":")[1:] {
+			if end := strings.IndexAny(id, "(+"); end >= 0 {
+				id = id[:end]
+			}
+			if id != "" {
+				jobs = append(jobs, id)
+			}
+		}
+	}
+	return jobs
+}
+
+// DependencyChains counts the distinct chains of the pending jobs waiting for
+// a dependency, keyed by job id with the jobs they depend on. Jobs linked
+// directly or through other jobs belong to the same chain.
+func DependencyChains(dependencies map[string][]string) float64 {
+	parent := make(map[string]string)
+	var root func(job string) string
+	root = func(job string) string {
+		if p, ok := parent[job]; ok && p != job {
+			parent[job] = root(p)
+			return parent[job]
+		}
+		parent[job] = job
+		return job
+	}
+	for job, jobs := range dependencies {
+		for _, dep := range jobs {
+			parent[root(dep)] = root(job)
+		}
+	}
+	chains := make(map[string]bool)
+	for job := range dependencies {
+		chains[root(job)] = true
+	}
+	return float64(len(chains))
+}
+
+// QueueJob is a job of the queue collector, from squeue or slurmrestd
+type QueueJob struct {
+	partition string
+	state     string
+	cpus      float64
+	reason    string
+	user      string
+	// Zero if not reported
+	submit time.Time
+	// Requested nodes, -1 if not reported
+	nodes      int
+	id         string
+	dependency string
+}
+
+// ParseQueueJobs takes the output of squeue in the format of QueueData,
+// older formats without the last columns are accepted as well. The fields
+// are separated with "|", the partitions, reasons and dependencies may
+// contain commas.
+func ParseQueueJobs(input []byte) []*QueueJob {
+	var jobs []*QueueJob
+	for _, line := range strings.Split(string(input), "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) < 5 {
+			continue
+		}
+		cores, _ := strconv.Atoi(fields[2])
+		job := &QueueJob{
+			partition: strings.TrimSpace(fields[0]),
+			state:     fields[1],
+			cpus:      float64(cores),
+			reason:    fields[3],
+			user:      strings.TrimSpace(fields[4]),
+			nodes:     -1,
+		}
+		if len(fields) > 5 {
+			job.submit, _ = time.ParseInLocation("2006-01-02T15:04:05", strings.TrimSpace(fields[5]), time.Local)
+		}
+		if len(fields) > 6 {
+			job.nodes, _ = strconv.Atoi(strings.TrimSpace(fields[6]))
+		}
+		if len(fields) > 8 {
+			job.id = strings.TrimSpace(fields[7])
+			job.dependency = fields[8]
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
 func ParseQueueMetrics(input []byte) *QueueMetrics {
+	return QueueJobsMetrics(ParseQueueJobs(input))
+}
+
+// QueueJobsMetrics aggregates the jobs of the queue
+func QueueJobsMetrics(jobs []*QueueJob) *QueueMetrics {
 	qm := QueueMetrics{
 		pending:       make(NNVal),
 		running:       make(NVal),
@@ -106,73 +224,73 @@ func ParseQueueMetrics(input []byte) *QueueMetrics {
 		c_timeout:     make(NVal),
 		c_preempted:   make(NVal),
 		c_node_fail:   make(NVal),
+
+		oldest_pending_part: make(map[string]time.Time),
+		jobs_by_nodes:       make(NVal),
 	}
-	lines := strings.Split(string(input), "\n")
-	for _, line := range lines {
-		if strings.Contains(line, ",") {
-			part := strings.Split(line, ",")[0]
-			part = strings.TrimSpace(part)
-			state := strings.Split(line, ",")[1]
-			cores_i, _ := strconv.Atoi(strings.Split(line, ",")[2])
-			cores := float64(cores_i)
-			user := strings.Split(line, ",")[4]
-			user = strings.TrimSpace(user)
-			reason := strings.Split(line, ",")[3]
-			switch state {
-			case "PENDING":
-				qm.pending.Incr2(reason, user, part, 1)
-				qm.c_pending.Incr2(reason, user, part, cores)
-			case "RUNNING":
-				qm.running.Incr(user, part, 1)
-				qm.c_running.Incr(user, part, cores)
-			case "SUSPENDED":
-				qm.suspended.Incr(user, part, 1)
-				qm.suspended.Incr(user, part, cores)
-			case "CANCELLED":
-				qm.cancelled.Incr(user, part, 1)
-				qm.c_cancelled.Incr(user, part, cores)
-			case "COMPLETING":
-				qm.completing.Incr(user, part, 1)
-				qm.c_completing.Incr(user, part, cores)
-			case "COMPLETED":
-				qm.completed.Incr(user, part, 1)
-				qm.c_completed.Incr(user, part, cores)
-			case "CONFIGURING":
-				qm.configuring.Incr(user, part, 1)
-				qm.c_configuring.Incr(user, part, cores)
-			case "FAILED":
-				qm.failed.Incr(user, part, 1)
-				qm.c_failed.Incr(user, part, cores)
-			case "TIMEOUT":
-				qm.timeout.Incr(user, part, 1)
-				qm.c_timeout.Incr(user, part, cores)
-			case "PREEMPTED":
-				qm.preempted.Incr(user, part, 1)
-				qm.c_preempted.Incr(user, part, cores)
-			case "NODE_FAIL":
-				qm.node_fail.Incr(user, part, 1)
-				qm.c_node_fail.Incr(user, part, cores)
+	dependencies := make(map[string][]string)
+	for _, job := range jobs {
+		part, state, cores, reason, user := job.partition, job.state, job.cpus, job.reason, job.user
+		if job.nodes >= 0 && (state == "PENDING" || state == "RUNNING") {
+			qm.jobs_by_nodes.Incr(NodesBucket(job.nodes), strings.ToLower(state), 1)
+		}
+		switch state {
+		case "PENDING":
+			qm.pending.Incr2(reason, user, part, 1)
+			qm.c_pending.Incr2(reason, user, part, cores)
+			if strings.HasPrefix(reason, "Dependency") {
+				qm.dependency++
+				if job.id != "" {
+					dependencies[job.id] = DependencyJobs(job.dependency)
+				}
 			}
+			if !job.submit.IsZero() {
+				if qm.oldest_pending.IsZero() || job.submit.Before(qm.oldest_pending) {
+					qm.oldest_pending = job.submit
+				}
+				if oldest, ok := qm.oldest_pending_part[part]; !ok || job.submit.Before(oldest) {
+					qm.oldest_pending_part[part] = job.submit
+				}
+			}
+		case "RUNNING":
+			qm.running.Incr(user, part, 1)
+			qm.c_running.Incr(user, part, cores)
+		case "SUSPENDED":
+			qm.suspended.Incr(user, part, 1)
+			qm.suspended.Incr(user, part, cores)
+		case "CANCELLED":
+			qm.cancelled.Incr(user, part, 1)
+			qm.c_cancelled.Incr(user, part, cores)
+		case "COMPLETING":
+			qm.completing.Incr(user, part, 1)
+			qm.c_completing.Incr(user, part, cores)
+		case "COMPLETED":
+			qm.completed.Incr(user, part, 1)
+			qm.c_completed.Incr(user, part, cores)
+		case "CONFIGURING":
+			qm.configuring.Incr(user, part, 1)
+			qm.c_configuring.Incr(user, part, cores)
+		case "FAILED":
+			qm.failed.Incr(user, part, 1)
+			qm.c_failed.Incr(user, part, cores)
+		case "TIMEOUT":
+			qm.timeout.Incr(user, part, 1)
+			qm.c_timeout.Incr(user, part, cores)
+		case "PREEMPTED":
+			qm.preempted.Incr(user, part, 1)
+			qm.c_preempted.Incr(user, part, cores)
+		case "NODE_FAIL":
+			qm.node_fail.Incr(user, part, 1)
+			qm.c_node_fail.Incr(user, part, cores)
 		}
 	}
+	qm.dependency_chains = DependencyChains(dependencies)
 	return &qm
 }
 
 // Execute the squeue command and return its output
 func QueueData() []byte {
-	cmd := exec.Command("squeue", "-h", "-o %P,%T,%C,%r,%u")
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		log.Fatal(err)
-	}
-	if err := cmd.Start(); err != nil {
-		log.Fatal(err)
-	}
-	out, _ := ioutil.ReadAll(stdout)
-	if err := cmd.Wait(); err != nil {
-		log.Fatal(err)
-	}
-	return out
+	return CollectorData("queue", "squeue", "-h", "-o", "%P|%T|%C|%r|%u|%V|%D|%i|%E")
 }
 
 /*
@@ -183,54 +301,64 @@ func QueueData() []byte {
 
 func NewQueueCollector() *QueueCollector {
 	return &QueueCollector{
-		pending:           prometheus.NewDesc("slurm_queue_pending", "Pending jobs in queue", []string{"user", "partition", "reason"}, nil),
-		running:           prometheus.NewDesc("slurm_queue_running", "Running jobs in the cluster", []string{"user", "partition"}, nil),
-		suspended:         prometheus.NewDesc("slurm_queue_suspended", "Suspended jobs in the cluster", []string{"user", "partition"}, nil),
-		cancelled:         prometheus.NewDesc("slurm_queue_cancelled", "Cancelled jobs in the cluster", []string{"user", "partition"}, nil),
-		completing:        prometheus.NewDesc("slurm_queue_completing", "Completing jobs in the cluster", []string{"user", "partition"}, nil),
-		completed:         prometheus.NewDesc("slurm_queue_completed", "Completed jobs in the cluster", []string{"user", "partition"}, nil),
-		configuring:       prometheus.NewDesc("slurm_queue_configuring", "Configuring jobs in the cluster", []string{"user", "partition"}, nil),
-		failed:            prometheus.NewDesc("slurm_queue_failed", "Number of failed jobs", []string{"user", "partition"}, nil),
-		timeout:           prometheus.NewDesc("slurm_queue_timeout", "Jobs stopped by timeout", []string{"user", "partition"}, nil),
-		preempted:         prometheus.NewDesc("slurm_queue_preempted", "Number of preempted jobs", []string{"user", "partition"}, nil),
-		node_fail:         prometheus.NewDesc("slurm_queue_node_fail", "Number of jobs stopped due to node fail", []string{"user", "partition"}, nil),
-		cores_pending:     prometheus.NewDesc("slurm_cores_pending", "Pending cores in queue", []string{"user", "partition", "reason"}, nil),
-		cores_running:     prometheus.NewDesc("slurm_cores_running", "Running cores in the cluster", []string{"user", "partition"}, nil),
-		cores_suspended:   prometheus.NewDesc("slurm_cores_suspended", "Suspended cores in the cluster", []string{"user", "partition"}, nil),
-		cores_cancelled:   prometheus.NewDesc("slurm_cores_cancelled", "Cancelled cores in the cluster", []string{"user", "partition"}, nil),
-		cores_completing:  prometheus.NewDesc("slurm_cores_completing", "Completing cores in the cluster", []string{"user", "partition"}, nil),
-		cores_completed:   prometheus.NewDesc("slurm_cores_completed", "Completed cores in the cluster", []string{"user", "partition"}, nil),
-		cores_configuring: prometheus.NewDesc("slurm_cores_configuring", "Configuring cores in the cluster", []string{"user", "partition"}, nil),
-		cores_failed:      prometheus.NewDesc("slurm_cores_failed", "Number of failed cores", []string{"user", "partition"}, nil),
-		cores_timeout:     prometheus.NewDesc("slurm_cores_timeout", "Cores stopped by timeout", []string{"user", "partition"}, nil),
-		cores_preempted:   prometheus.NewDesc("slurm_cores_preempted", "Number of preempted cores", []string{"user", "partition"}, nil),
-		cores_node_fail:   prometheus.NewDesc("slurm_cores_node_fail", "Number of cores stopped due to node fail", []string{"user", "partition"}, nil),
+		pending:             prometheus.NewDesc("slurm_queue_pending", "Pending jobs in queue", []string{"user", "partition", "reason"}, nil),
+		running:             prometheus.NewDesc("slurm_queue_running", "Running jobs in the cluster", []string{"user", "partition"}, nil),
+		suspended:           prometheus.NewDesc("slurm_queue_suspended", "Suspended jobs in the cluster", []string{"user", "partition"}, nil),
+		cancelled:           prometheus.NewDesc("slurm_queue_cancelled", "Cancelled jobs in the cluster", []string{"user", "partition"}, nil),
+		completing:          prometheus.NewDesc("slurm_queue_completing", "Completing jobs in the cluster", []string{"user", "partition"}, nil),
+		completed:           prometheus.NewDesc("slurm_queue_completed", "Completed jobs in the cluster", []string{"user", "partition"}, nil),
+		configuring:         prometheus.NewDesc("slurm_queue_configuring", "Configuring jobs in the cluster", []string{"user", "partition"}, nil),
+		failed:              prometheus.NewDesc("slurm_queue_failed", "Number of failed jobs", []string{"user", "partition"}, nil),
+		timeout:             prometheus.NewDesc("slurm_queue_timeout", "Jobs stopped by timeout", []string{"user", "partition"}, nil),
+		preempted:           prometheus.NewDesc("slurm_queue_preempted", "Number of preempted jobs", []string{"user", "partition"}, nil),
+		node_fail:           prometheus.NewDesc("slurm_queue_node_fail", "Number of jobs stopped due to node fail", []string{"user", "partition"}, nil),
+		cores_pending:       prometheus.NewDesc("slurm_cores_pending", "Pending cores in queue", []string{"user", "partition", "reason"}, nil),
+		cores_running:       prometheus.NewDesc("slurm_cores_running", "Running cores in the cluster", []string{"user", "partition"}, nil),
+		cores_suspended:     prometheus.NewDesc("slurm_cores_suspended", "Suspended cores in the cluster", []string{"user", "partition"}, nil),
+		cores_cancelled:     prometheus.NewDesc("slurm_cores_cancelled", "Cancelled cores in the cluster", []string{"user", "partition"}, nil),
+		cores_completing:    prometheus.NewDesc("slurm_cores_completing", "Completing cores in the cluster", []string{"user", "partition"}, nil),
+		cores_completed:     prometheus.NewDesc("slurm_cores_completed", "Completed cores in the cluster", []string{"user", "partition"}, nil),
+		cores_configuring:   prometheus.NewDesc("slurm_cores_configuring", "Configuring cores in the cluster", []string{"user", "partition"}, nil),
+		cores_failed:        prometheus.NewDesc("slurm_cores_failed", "Number of failed cores", []string{"user", "partition"}, nil),
+		cores_timeout:       prometheus.NewDesc("slurm_cores_timeout", "Cores stopped by timeout", []string{"user", "partition"}, nil),
+		cores_preempted:     prometheus.NewDesc("slurm_cores_preempted", "Number of preempted cores", []string{"user", "partition"}, nil),
+		cores_node_fail:     prometheus.NewDesc("slurm_cores_node_fail", "Number of cores stopped due to node fail", []string{"user", "partition"}, nil),
+		oldest_pending:      prometheus.NewDesc("slurm_queue_oldest_pending_seconds", "Age of the longest waiting pending job in the cluster", nil, nil),
+		oldest_pending_part: prometheus.NewDesc("slurm_queue_partition_oldest_pending_seconds", "Age of the longest waiting pending job per partition", []string{"partition"}, nil),
+		jobs_by_nodes:       prometheus.NewDesc("slurm_queue_jobs_by_nodes", "Pending and running jobs by number of requested nodes", []string{"bucket", "state"}, nil),
+		dependency:          prometheus.NewDesc("slurm_queue_dependency_jobs", "Pending jobs waiting for a dependency", nil, nil),
+		dependency_chains:   prometheus.NewDesc("slurm_queue_dependency_chains", "Distinct chains of the pending jobs waiting for a dependency", nil, nil),
 	}
 }
 
 type QueueCollector struct {
-	pending           *prometheus.Desc
-	running           *prometheus.Desc
-	suspended         *prometheus.Desc
-	cancelled         *prometheus.Desc
-	completing        *prometheus.Desc
-	completed         *prometheus.Desc
-	configuring       *prometheus.Desc
-	failed            *prometheus.Desc
-	timeout           *prometheus.Desc
-	preempted         *prometheus.Desc
-	node_fail         *prometheus.Desc
-	cores_pending     *prometheus.Desc
-	cores_running     *prometheus.Desc
-	cores_suspended   *prometheus.Desc
-	cores_cancelled   *prometheus.Desc
-	cores_completing  *prometheus.Desc
-	cores_completed   *prometheus.Desc
-	cores_configuring *prometheus.Desc
-	cores_failed      *prometheus.Desc
-	cores_timeout     *prometheus.Desc
-	cores_preempted   *prometheus.Desc
-	cores_node_fail   *prometheus.Desc
+	pending             *prometheus.Desc
+	running             *prometheus.Desc
+	suspended           *prometheus.Desc
+	cancelled           *prometheus.Desc
+	completing          *prometheus.Desc
+	completed           *prometheus.Desc
+	configuring         *prometheus.Desc
+	failed              *prometheus.Desc
+	timeout             *prometheus.Desc
+	preempted           *prometheus.Desc
+	node_fail           *prometheus.Desc
+	cores_pending       *prometheus.Desc
+	cores_running       *prometheus.Desc
+	cores_suspended     *prometheus.Desc
+	cores_cancelled     *prometheus.Desc
+	cores_completing    *prometheus.Desc
+	cores_completed     *prometheus.Desc
+	cores_configuring   *prometheus.Desc
+	cores_failed        *prometheus.Desc
+	cores_timeout       *prometheus.Desc
+	cores_preempted     *prometheus.Desc
+	cores_node_fail     *prometheus.Desc
+	oldest_pending      *prometheus.Desc
+	oldest_pending_part *prometheus.Desc
+	jobs_by_nodes       *prometheus.Desc
+	dependency          *prometheus.Desc
+	dependency_chains   *prometheus.Desc
 }
 
 func (qc *QueueCollector) Describe(ch chan<- *prometheus.Desc) {
@@ -256,6 +384,11 @@ func (qc *QueueCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- qc.cores_timeout
 	ch <- qc.cores_preempted
 	ch <- qc.cores_node_fail
+	ch <- qc.oldest_pending
+	ch <- qc.oldest_pending_part
+	ch <- qc.jobs_by_nodes
+	ch <- qc.dependency
+	ch <- qc.dependency_chains
 }
 
 func (qc *QueueCollector) Collect(ch chan<- prometheus.Metric) {
@@ -285,6 +418,16 @@ func (qc *QueueCollector) Collect(ch chan<- prometheus.Metric) {
 	PushMetric(qm.c_timeout, ch, qc.cores_timeout, "")
 	PushMetric(qm.c_preempted, ch, qc.cores_preempted, "")
 	PushMetric(qm.c_node_fail, ch, qc.cores_node_fail, "")
+	PushMetric(qm.jobs_by_nodes, ch, qc.jobs_by_nodes, "")
+	ch <- prometheus.MustNewConstMetric(qc.dependency, prometheus.GaugeValue, qm.dependency)
+	ch <- prometheus.MustNewConstMetric(qc.dependency_chains, prometheus.GaugeValue, qm.dependency_chains)
+	now := time.Now()
+	if !qm.oldest_pending.IsZero() {
+		ch <- prometheus.MustNewConstMetric(qc.oldest_pending, prometheus.GaugeValue, now.Sub(qm.oldest_pending).Seconds())
+	}
+	for part, submit := range qm.oldest_pending_part {
+		ch <- prometheus.MustNewConstMetric(qc.oldest_pending_part, prometheus.GaugeValue, now.Sub(submit).Seconds(), part)
+	}
 }
 
 func PushMetric(m map[string]map[string]float64, ch chan<- prometheus.Metric, coll *prometheus.Desc, a_label string) {
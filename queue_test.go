@@ -19,6 +19,9 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestParseQueueMetrics(t *testing.T) {
@@ -30,3 +33,55 @@ func TestParseQueueMetrics(t *testing.T) {
 	data, err := ioutil.ReadAll(file)
 	t.Logf("%+v", ParseQueueMetrics(data))
 }
+
+func TestQueueOldestPending(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/squeue_pending.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	qm := ParseQueueMetrics(data)
+	at := func(value string) time.Time {
+		submit, _ := time.ParseInLocation("2006-01-02T15:04:05", value, time.Local)
+		return submit
+	}
+
+	assert.Equal(t, at("2024-03-10T22:15:00"), qm.oldest_pending)
+	assert.Equal(t, at("2024-03-10T22:15:00"), qm.oldest_pending_part["gpu"])
+	assert.Equal(t, at("2024-03-11T07:30:00"), qm.oldest_pending_part["cpu"])
+	// Running jobs are not waiting
+	assert.NotContains(t, qm.oldest_pending_part, "debug")
+}
+
+func TestQueueJobsByNodes(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/squeue_nodes.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	qm := ParseQueueMetrics(data)
+
+	assert.Equal(t, NVal{
+		"1":    {"pending": 2},
+		"2-4":  {"running": 2, "pending": 1},
+		"5-16": {"running": 1},
+		"17+":  {"pending": 1},
+	}, qm.jobs_by_nodes)
+
+	// A reason with commas does not shift the following fields
+	assert.Equal(t, 1.0, qm.pending["ReqNodeNotAvail, UnavailableNodes:gpu[01,03]"]["erin"]["gpu"])
+}
+
+func TestQueueDependency(t *testing.T) {
+	assert.Equal(t, []string{"200", "201", "202"}, DependencyJobs("afterok:200(unfulfilled),afterany:201:202+10(unfulfilled)"))
+	assert.Empty(t, DependencyJobs("singleton"))
+	assert.Empty(t, DependencyJobs("(null)"))
+
+	data, err := ioutil.ReadFile("test_data/squeue_dependency.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	qm := ParseQueueMetrics(data)
+
+	// 103 waits for 102 which waits for 101, 104 and 105 share 201, 106 can never start
+	assert.Equal(t, 5.0, qm.dependency)
+	assert.Equal(t, 3.0, qm.dependency_chains)
+}
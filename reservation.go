@@ -0,0 +1,160 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Execute the scontrol command to read the reservations, one line per reservation
+func ReservationsData() []byte {
+	return CollectorData("reservations", "scontrol", "show", "reservation", "-o")
+}
+
+// Execute the squeue command to list the running jobs with their
+// reservation and nodes, e.g. "1002|(null)|cpu[03-04]"
+func ReservationJobsData() []byte {
+	return CollectorData("reservations", "squeue", "-a", "-h", "-t", "RUNNING", "-o", "%i|%v|%N")
+}
+
+// ParseReservationNodes takes the output of scontrol show reservation -o
+// It returns the expanded nodes of every active reservation, reservations
+// which did not start yet may legitimately have other jobs on their nodes
+func ParseReservationNodes(input []byte) map[string][]string {
+	reservations := make(map[string][]string)
+	for _, line := range strings.Split(string(input), "\n") {
+		record := ParseScontrolRecord(line)
+		name, ok := record["ReservationName"]
+		if !ok || record["State"] != "ACTIVE" {
+			continue
+		}
+		reservations[name] = ExpandHostlist(record["Nodes"])
+	}
+	return reservations
+}
+
+// Nodes, CPUs and state of a reservation
+type ReservationMetrics struct {
+	state string
+	nodes float64
+	cpus  float64
+}
+
+// ParseReservations takes the output of scontrol show reservation -o
+// It returns the metrics of every reservation, none for the
+// "No reservations in the system" of a cluster without reservations
+func ParseReservations(input []byte) map[string]*ReservationMetrics {
+	reservations := make(map[string]*ReservationMetrics)
+	for _, line := range strings.Split(string(input), "\n") {
+		record := ParseScontrolRecord(line)
+		name, ok := record["ReservationName"]
+		if !ok {
+			continue
+		}
+		rm := &ReservationMetrics{state: strings.ToLower(record["State"])}
+		rm.nodes, _ = strconv.ParseFloat(record["NodeCnt"], 64)
+		// Older Slurm versions have no TRES of the reservation
+		if cpus, ok := ParseTRES(record["TRES"])["cpu"]; ok {
+			rm.cpus = cpus
+		} else {
+			rm.cpus, _ = strconv.ParseFloat(record["CoreCnt"], 64)
+		}
+		reservations[name] = rm
+	}
+	return reservations
+}
+
+// ParseForeignJobs counts per reservation the running jobs outside of it
+// which use at least one of its nodes, from the output of ReservationJobsData.
+// Every active reservation is returned, with 0 if there is no foreign job.
+func ParseForeignJobs(reservations map[string][]string, input []byte) map[string]float64 {
+	reserved := make(map[string]string)
+	foreign := make(map[string]float64)
+	for name, nodes := range reservations {
+		foreign[name] = 0
+		for _, node := range nodes {
+			reserved[node] = name
+		}
+	}
+	for _, line := range strings.Split(string(input), "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) != 3 {
+			continue
+		}
+		// A job on several nodes of a reservation counts once
+		counted := make(map[string]bool)
+		for _, node := range ExpandHostlist(fields[2]) {
+			name, ok := reserved[node]
+			if !ok || name == fields[1] || counted[name] {
+				continue
+			}
+			counted[name] = true
+			foreign[name]++
+		}
+	}
+	return foreign
+}
+
+/*
+ * Implement the Prometheus Collector interface and feed the
+ * Slurm reservation metrics into it.
+ * https://godoc.org/github.com/prometheus/client_golang/prometheus#Collector
+ */
+
+func NewReservationsCollector() *ReservationsCollector {
+	labels := []string{"reservation", "state"}
+	return &ReservationsCollector{
+		foreignJobs: prometheus.NewDesc("slurm_reservation_foreign_jobs", "Running jobs outside of the reservation on its nodes", []string{"name"}, nil),
+		nodes:       prometheus.NewDesc("slurm_reservation_nodes", "Nodes of the reservation", labels, nil),
+		cpus:        prometheus.NewDesc("slurm_reservation_cpus", "CPUs of the reservation", labels, nil),
+		active:      prometheus.NewDesc("slurm_reservation_active", "Whether the reservation has started and not ended yet", labels, nil),
+	}
+}
+
+type ReservationsCollector struct {
+	foreignJobs *prometheus.Desc
+	nodes       *prometheus.Desc
+	cpus        *prometheus.Desc
+	active      *prometheus.Desc
+}
+
+// Send all metric descriptions
+func (rc *ReservationsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- rc.foreignJobs
+	ch <- rc.nodes
+	ch <- rc.cpus
+	ch <- rc.active
+}
+
+func (rc *ReservationsCollector) Collect(ch chan<- prometheus.Metric) {
+	data := ReservationsData()
+	for name, rm := range ParseReservations(data) {
+		active := 0.0
+		if rm.state == "active" {
+			active = 1
+		}
+		ch <- prometheus.MustNewConstMetric(rc.nodes, prometheus.GaugeValue, rm.nodes, name, rm.state)
+		ch <- prometheus.MustNewConstMetric(rc.cpus, prometheus.GaugeValue, rm.cpus, name, rm.state)
+		ch <- prometheus.MustNewConstMetric(rc.active, prometheus.GaugeValue, active, name, rm.state)
+	}
+	reservations := ParseReservationNodes(data)
+	for name, count := range ParseForeignJobs(reservations, ReservationJobsData()) {
+		ch <- prometheus.MustNewConstMetric(rc.foreignJobs, prometheus.GaugeValue, count, name)
+	}
+}
@@ -0,0 +1,60 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseForeignJobs(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/scontrol_reservations.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	reservations := ParseReservationNodes(data)
+	assert.Len(t, reservations, 2)
+	assert.Equal(t, []string{"cpu03", "cpu04", "gpu01"}, reservations["course"])
+
+	jobs, err := ioutil.ReadFile("test_data/squeue_reservation_jobs.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	foreign := ParseForeignJobs(reservations, jobs)
+
+	// Jobs 1002 and 1003 run on cpu04 outside of the course reservation,
+	// the future reservation of cpu05 is not active yet
+	assert.Equal(t, map[string]float64{"maint": 0, "course": 2}, foreign)
+}
+
+func TestParseReservations(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/scontrol_reservations.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	reservations := ParseReservations(data)
+	assert.Len(t, reservations, 3)
+	assert.Equal(t, &ReservationMetrics{state: "active", nodes: 3, cpus: 96}, reservations["course"])
+	assert.Equal(t, &ReservationMetrics{state: "inactive", nodes: 1, cpus: 32}, reservations["future"])
+
+	// The cores for older Slurm versions without the TRES
+	reservations = ParseReservations([]byte("ReservationName=old StartTime=2026-10-14T08:00:00 Nodes=cpu01 NodeCnt=1 CoreCnt=16 State=ACTIVE\n"))
+	assert.Equal(t, 16.0, reservations["old"].cpus)
+
+	assert.Empty(t, ParseReservations([]byte("No reservations in the system\n")))
+}
@@ -0,0 +1,641 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+// NodeDataSource provides the node metrics, either by executing the Slurm
+// commands or by querying the REST API of slurmrestd. Reading and parsing
+// are separate steps, so the time spent in Slurm and in the exporter can be
+// told apart.
+type NodeDataSource interface {
+	// Read returns the raw node data from Slurm
+	Read() ([]byte, error)
+	// Parse returns the metrics per node from the raw node data
+	Parse(data []byte) (map[string]*NodeMetrics, error)
+}
+
+// ExecNodeSource parses the output of sinfo
+type ExecNodeSource struct{}
+
+func (ExecNodeSource) Read() ([]byte, error) {
+	return NodeData()
+}
+
+func (ExecNodeSource) Parse(data []byte) (map[string]*NodeMetrics, error) {
+	return ParseNodeMetrics(data), nil
+}
+
+// JSONNodeSource parses the output of sinfo --json, the same typed node
+// objects as returned by slurmrestd, available since Slurm 21.08. Their
+// schema depends on the release of Slurm.
+type JSONNodeSource struct {
+	release string
+}
+
+func (JSONNodeSource) Read() ([]byte, error) {
+	return NodeJSONData()
+}
+
+func (s JSONNodeSource) Parse(data []byte) (map[string]*NodeMetrics, error) {
+	nodes, err := ParseRESTNodes(data, s.release)
+	if err != nil {
+		return nil, fmt.Errorf("sinfo --json: %w", err)
+	}
+	return nodes, nil
+}
+
+// Collectors which can read their data from slurmrestd
+var restCollectors = []string{"job", "node", "partitions", "queue"}
+
+// -collector.<name>.source of the collectors reading from slurmrestd
+var collectorSources = make(map[string]*string)
+
+// CollectorSource returns the backend of a collector, its own
+// -collector.<name>.source if set or else -slurm.source
+func CollectorSource(collector string) string {
+	if source, ok := collectorSources[collector]; ok && *source != "" {
+		return *source
+	}
+	return *slurmSource
+}
+
+// resolveSource validates a backend, exec or rest. An empty source selects
+// rest if the URL of slurmrestd is set.
+func resolveSource(source, url string) (string, error) {
+	if source == "" {
+		source = "exec"
+		if url != "" {
+			source = "rest"
+		}
+	}
+	switch source {
+	case "exec":
+		return source, nil
+	case "rest":
+		if url == "" {
+			return "", fmt.Errorf("the rest source requires the URL of slurmrestd")
+		}
+		return source, nil
+	}
+	return "", fmt.Errorf("invalid source %q, expected exec or rest", source)
+}
+
+// NewNodeDataSource returns the node data source selected with -slurm.source,
+// exec or rest. An empty source selects rest if the URL of slurmrestd is set.
+// The format, text or json, selects the output of sinfo parsed by exec.
+// The release of Slurm selects the schema of the JSON nodes.
+func NewNodeDataSource(source, format, url, token, version, release string) (NodeDataSource, error) {
+	source, err := resolveSource(source, url)
+	if err != nil {
+		return nil, err
+	}
+	if source == "rest" {
+		return RESTNodeSource{client: NewRESTClient("node", url, token, version), release: release}, nil
+	}
+	switch format {
+	case "", "text":
+		return ExecNodeSource{}, nil
+	case "json":
+		return JSONNodeSource{release: release}, nil
+	}
+	return nil, fmt.Errorf("invalid format %q, expected text or json", format)
+}
+
+// NewPartitionDataSource returns the partition data source, exec or rest
+func NewPartitionDataSource(source, url, token, version, release string) (PartitionDataSource, error) {
+	source, err := resolveSource(source, url)
+	if err != nil {
+		return nil, err
+	}
+	if source == "rest" {
+		return RESTPartitionSource{client: NewRESTClient("partitions", url, token, version), release: release}, nil
+	}
+	return ExecPartitionSource{}, nil
+}
+
+// NewJobDataSource returns the job data source of a collector, exec or rest
+func NewJobDataSource(collector, source, url, token, version string) (JobDataSource, error) {
+	source, err := resolveSource(source, url)
+	if err != nil {
+		return nil, err
+	}
+	if source == "rest" {
+		return RESTJobSource{client: NewRESTClient(collector, url, token, version), collector: collector}, nil
+	}
+	return ExecJobSource{}, nil
+}
+
+// RESTClient queries the REST API of slurmrestd, authenticated with a JWT
+// on behalf of a collector. The requests behave like the commands of the
+// collector: they time out after its -collector.<name>.timeout or
+// -slurm.timeout, a success updates its last success timestamp and a
+// rejected token disables the collector.
+type RESTClient struct {
+	collector string
+	url       string
+	token     string
+	version   string
+	client    *http.Client
+}
+
+func NewRESTClient(collector, url, token, version string) *RESTClient {
+	return &RESTClient{
+		collector: collector,
+		url:       strings.TrimSuffix(url, "/"),
+		token:     token,
+		version:   version,
+		client:    &http.Client{},
+	}
+}
+
+// Get returns the body of an endpoint of the slurm API, e.g. "nodes"
+func (c *RESTClient) Get(endpoint string) ([]byte, error) {
+	if collectorDenied(c.collector) {
+		return nil, ErrPermissionDenied
+	}
+	url := fmt.Sprintf("%s/slurm/%s/%s", c.url, c.version, endpoint)
+	ctx := context.Background()
+	if timeout := CollectorTimeout(c.collector); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("X-SLURM-USER-TOKEN", c.token)
+	}
+	resp, err := c.client.Do(req)
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("%s: timed out after %s", url, CollectorTimeout(c.collector))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		markDenied(c.collector)
+		return nil, fmt.Errorf("%s: %w, collector disabled: %s", url, ErrPermissionDenied, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	collectorLastSuccess.WithLabelValues(c.collector).SetToCurrentTime()
+	return body, nil
+}
+
+// RESTNodeSource reads the nodes from slurmrestd, the schema of the nodes
+// depends on the release of Slurm
+type RESTNodeSource struct {
+	client  *RESTClient
+	release string
+}
+
+func (s RESTNodeSource) Read() ([]byte, error) {
+	return s.client.Get("nodes")
+}
+
+func (s RESTNodeSource) Parse(data []byte) (map[string]*NodeMetrics, error) {
+	nodes, err := ParseRESTNodes(data, s.release)
+	if err != nil {
+		return nil, fmt.Errorf("slurmrestd: %w", err)
+	}
+	return nodes, nil
+}
+
+// restNumber is a number, older API versions return it plain while newer
+// ones wrap it as {"set": true, "infinite": false, "number": 42}
+type restNumber float64
+
+func (n *restNumber) UnmarshalJSON(data []byte) error {
+	var plain float64
+	if err := json.Unmarshal(data, &plain); err == nil {
+		*n = restNumber(plain)
+		return nil
+	}
+	var wrapped struct {
+		Number float64 `json:"number"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err != nil {
+		return err
+	}
+	*n = restNumber(wrapped.Number)
+	return nil
+}
+
+// restState is the state of a node, a single string in older API
+// versions and a list of the base state and its flags in newer ones
+type restState []string
+
+func (s *restState) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*s = restState(strings.Split(single, "+"))
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*s = restState(list)
+	return nil
+}
+
+// Subset of a node in the slurmrestd nodes schema
+type restNode struct {
+	Name           string     `json:"name"`
+	State          restState  `json:"state"`
+	StateFlags     []string   `json:"state_flags"`
+	CPUs           uint64     `json:"cpus"`
+	AllocCPUs      uint64     `json:"alloc_cpus"`
+	CPULoad        restNumber `json:"cpu_load"`
+	Sockets        uint64     `json:"sockets"`
+	Cores          uint64     `json:"cores"`
+	Threads        uint64     `json:"threads"`
+	RealMemory     uint64     `json:"real_memory"`
+	AllocMemory    uint64     `json:"alloc_memory"`
+	FreeMemory     restNumber `json:"free_mem"`
+	FreeMemoryOld  restNumber `json:"free_memory"`
+	Gres           string     `json:"gres"`
+	GresUsed       string     `json:"gres_used"`
+	Features       []string   `json:"features"`
+	ActiveFeatures []string   `json:"active_features"`
+	Partitions     []string   `json:"partitions"`
+	TRES           string     `json:"tres"`
+	TRESUsed       string     `json:"tres_used"`
+}
+
+type restError struct {
+	Error       string `json:"error"`
+	Description string `json:"description"`
+}
+
+// ParseRESTNodes takes the response of the slurmrestd nodes endpoint or the
+// output of sinfo --json of a release of Slurm. It returns a map of metrics
+// per node, like ParseNodeMetrics. Releases before 23.02 report the flags of
+// the state in state_flags and the free memory in free_memory.
+func ParseRESTNodes(input []byte, release string) (map[string]*NodeMetrics, error) {
+	var response struct {
+		Nodes  []restNode  `json:"nodes"`
+		Errors []restError `json:"errors"`
+	}
+	if err := json.Unmarshal(input, &response); err != nil {
+		return nil, err
+	}
+	if err := responseErrors(response.Errors); err != nil {
+		return nil, err
+	}
+	legacy := LegacyNodeSchema(release)
+	nodes := make(map[string]*NodeMetrics)
+	for _, node := range response.Nodes {
+		state, freeMemory := node.State, node.FreeMemory
+		if legacy {
+			state = append(state, node.StateFlags...)
+			freeMemory = node.FreeMemoryOld
+		}
+		nm := &NodeMetrics{
+			cpuAlloc: node.AllocCPUs,
+			cpuTotal: node.CPUs,
+			// slurmrestd reports the load multiplied by 100
+			cpuLoad:  float64(node.CPULoad) / 100,
+			hasLoad:  true,
+			sockets:  node.Sockets,
+			cores:    node.Cores,
+			threads:  node.Threads,
+			memAlloc: node.AllocMemory,
+			memTotal: node.RealMemory,
+			memFree:  uint64(freeMemory),
+		}
+		nm.nodeStatus = strings.ToLower(strings.Join(state, "+"))
+		nm.nodeState = NodeBaseState(nm.nodeStatus)
+		nm.nodeFlags = NodeFlags(nm.nodeStatus)
+		// sinfo counts the CPUs of unavailable nodes as other, an
+		// oversubscribed node has no free CPUs left
+		free := uint64(0)
+		if nm.cpuAlloc < nm.cpuTotal {
+			free = nm.cpuTotal - nm.cpuAlloc
+		}
+		switch nm.nodeState {
+		case "down", "drained", "fail":
+			nm.cpuOther = free
+		default:
+			nm.cpuIdle = free
+		}
+		gres, gresUsed := node.Gres, node.GresUsed
+		if gres == "" {
+			gres = "(null)"
+		}
+		nm.parseGPUs(node.Name, gres, gresUsed)
+		if *gpuTRES {
+			nm.applyGPUTRES(ParseTRES(node.TRES), ParseTRES(node.TRESUsed))
+		}
+		nm.features = strings.Join(node.Features, ",")
+		nm.activeFeatures = strings.Join(node.ActiveFeatures, ",")
+		for _, partition := range node.Partitions {
+			nm.addPartition(partition)
+		}
+		nodes[node.Name] = nm
+	}
+	return nodes, nil
+}
+
+// responseErrors returns the first error reported by slurmrestd
+func responseErrors(errors []restError) error {
+	if len(errors) > 0 {
+		return fmt.Errorf("%s %s", errors[0].Error, errors[0].Description)
+	}
+	return nil
+}
+
+// Subset of a job in the slurmrestd jobs schema
+type restJob struct {
+	JobID         restNumber `json:"job_id"`
+	JobState      restState  `json:"job_state"`
+	Partition     string     `json:"partition"`
+	UserName      string     `json:"user_name"`
+	CPUs          restNumber `json:"cpus"`
+	NodeCount     restNumber `json:"node_count"`
+	StateReason   string     `json:"state_reason"`
+	SubmitTime    restNumber `json:"submit_time"`
+	StartTime     restNumber `json:"start_time"`
+	Dependency    string     `json:"dependency"`
+	MemoryPerNode restNumber `json:"memory_per_node"`
+	MemoryPerCPU  restNumber `json:"memory_per_cpu"`
+}
+
+// state returns the base state of a job, e.g. PENDING
+func (job restJob) state() string {
+	if len(job.JobState) == 0 {
+		return ""
+	}
+	return strings.ToUpper(job.JobState[0])
+}
+
+// ParseRESTJobs takes the response of the slurmrestd jobs endpoint
+func ParseRESTJobs(input []byte) ([]restJob, error) {
+	var response struct {
+		Jobs   []restJob   `json:"jobs"`
+		Errors []restError `json:"errors"`
+	}
+	if err := json.Unmarshal(input, &response); err != nil {
+		return nil, err
+	}
+	if err := responseErrors(response.Errors); err != nil {
+		return nil, err
+	}
+	return response.Jobs, nil
+}
+
+// RESTJobDetails returns the jobs like ParseJobMetrics, the time used is
+// counted from the start of the jobs which are no longer pending
+func RESTJobDetails(jobs []restJob, now time.Time) map[string]*JobDetails {
+	details := make(map[string]*JobDetails)
+	for _, job := range jobs {
+		jd := &JobDetails{
+			state:     strings.ToLower(job.state()),
+			partition: job.Partition,
+			user:      job.UserName,
+			cpus:      uint64(job.CPUs),
+			nodes:     uint64(job.NodeCount),
+			reason:    job.StateReason,
+		}
+		if jd.state != "pending" && job.StartTime > 0 {
+			jd.timeUsed = math.Max(now.Sub(time.Unix(int64(job.StartTime), 0)).Seconds(), 0)
+		}
+		details[fmt.Sprint(uint64(job.JobID))] = jd
+	}
+	return details
+}
+
+// RESTQueueJobs returns the jobs like ParseQueueJobs
+func RESTQueueJobs(jobs []restJob) []*QueueJob {
+	var queue []*QueueJob
+	for _, job := range jobs {
+		qj := &QueueJob{
+			partition:  job.Partition,
+			state:      job.state(),
+			cpus:       float64(job.CPUs),
+			reason:     job.StateReason,
+			user:       job.UserName,
+			nodes:      -1,
+			id:         fmt.Sprint(uint64(job.JobID)),
+			dependency: job.Dependency,
+		}
+		// An unset node count is missing or 0, plain or wrapped with "set": false
+		if job.NodeCount > 0 {
+			qj.nodes = int(job.NodeCount)
+		}
+		if job.SubmitTime > 0 {
+			qj.submit = time.Unix(int64(job.SubmitTime), 0)
+		}
+		queue = append(queue, qj)
+	}
+	return queue
+}
+
+// RESTJobSource reads the jobs of a collector from slurmrestd, failures
+// are logged and no jobs returned like with CollectorData
+type RESTJobSource struct {
+	client    *RESTClient
+	collector string
+}
+
+func (s RESTJobSource) jobs() []restJob {
+	body, err := s.client.Get("jobs")
+	if err == nil {
+		var jobs []restJob
+		if jobs, err = ParseRESTJobs(body); err == nil {
+			return jobs
+		}
+	}
+	if err != ErrPermissionDenied {
+		// Logged once when the collector was disabled
+		log.Errorf("Collector %s: slurmrestd: %v", s.collector, err)
+	}
+	return nil
+}
+
+func (s RESTJobSource) Jobs() map[string]*JobDetails {
+	return RESTJobDetails(s.jobs(), time.Now())
+}
+
+func (s RESTJobSource) QueueJobs() []*QueueJob {
+	return RESTQueueJobs(s.jobs())
+}
+
+// Subset of a partition in the slurmrestd partitions schema
+type restPartition struct {
+	Name string `json:"name"`
+	// Older API versions list the flags and the state of the partition
+	// directly, newer ones the state within "partition"
+	Flags     []string  `json:"flags"`
+	State     restState `json:"state"`
+	Partition struct {
+		State restState `json:"state"`
+	} `json:"partition"`
+}
+
+// ParseRESTPartitions takes the response of the slurmrestd partitions
+// endpoint. It returns the details of every partition like
+// ParseScontrolPartitions, Default=YES for the default partition and its
+// State, e.g. UP.
+func ParseRESTPartitions(input []byte) (map[string]map[string]string, error) {
+	var response struct {
+		Partitions []restPartition `json:"partitions"`
+		Errors     []restError     `json:"errors"`
+	}
+	if err := json.Unmarshal(input, &response); err != nil {
+		return nil, err
+	}
+	if err := responseErrors(response.Errors); err != nil {
+		return nil, err
+	}
+	partitions := make(map[string]map[string]string)
+	for _, partition := range response.Partitions {
+		record := map[string]string{"Default": "NO"}
+		for _, flag := range partition.Flags {
+			if strings.EqualFold(flag, "default") {
+				record["Default"] = "YES"
+			}
+		}
+		state := partition.Partition.State
+		if len(state) == 0 {
+			state = partition.State
+		}
+		if len(state) > 0 {
+			record["State"] = strings.ToUpper(state[0])
+		}
+		partitions[partition.Name] = record
+	}
+	return partitions, nil
+}
+
+// RESTPartitionMetrics aggregates the CPUs and nodes of the partitions from
+// the nodes and counts the pending jobs, like ParsePartitionsMetrics. Every
+// partition in the details is reported, even without any node.
+func RESTPartitionMetrics(details map[string]map[string]string, nodes map[string]*NodeMetrics, jobs []restJob) map[string]*PartitionMetrics {
+	partitions := make(map[string]*PartitionMetrics)
+	partition := func(name string) *PartitionMetrics {
+		pm, ok := partitions[name]
+		if !ok {
+			pm = &PartitionMetrics{}
+			partitions[name] = pm
+		}
+		return pm
+	}
+	for name := range details {
+		partition(name)
+	}
+	for _, nm := range nodes {
+		for _, name := range nm.partitions {
+			pm := partition(name)
+			pm.allocated += float64(nm.cpuAlloc)
+			pm.idle += float64(nm.cpuIdle)
+			pm.other += float64(nm.cpuOther)
+			pm.total += float64(nm.cpuTotal)
+			pm.nodes++
+		}
+	}
+	for _, job := range jobs {
+		if job.state() != "PENDING" {
+			continue
+		}
+		// Like squeue -r, a job pending in several partitions counts in each
+		for _, name := range strings.Split(job.Partition, ",") {
+			if pm, ok := partitions[name]; ok {
+				pm.pending++
+			}
+		}
+	}
+	return partitions
+}
+
+// RESTReqMem aggregates the memory requested by the jobs per partition,
+// like ParsePartitionReqMem the memory per node, else the memory per CPU
+// times the CPUs of the job. Jobs without a memory request are left out.
+func RESTReqMem(jobs []restJob) map[string]*ReqMemMetrics {
+	partitions := make(map[string]*ReqMemMetrics)
+	for _, job := range jobs {
+		mem := job.MemoryPerNode
+		if mem == 0 {
+			mem = job.MemoryPerCPU * job.CPUs
+		}
+		if mem == 0 {
+			continue
+		}
+		AddReqMem(partitions, job.Partition, uint64(mem))
+	}
+	return partitions
+}
+
+// RESTPartitionSource reads the partitions from slurmrestd, and their
+// nodes and jobs for the CPUs, pending jobs and requested memory. Every
+// endpoint is queried once per scrape. Failures are logged and the data
+// depending on them left out like with CollectorData.
+type RESTPartitionSource struct {
+	client  *RESTClient
+	release string
+}
+
+func (s RESTPartitionSource) get(endpoint string, parse func([]byte) error) bool {
+	body, err := s.client.Get(endpoint)
+	if err == nil {
+		err = parse(body)
+	}
+	if err == ErrPermissionDenied {
+		// Logged once when the collector was disabled
+		return false
+	}
+	if err != nil {
+		log.Errorf("Collector partitions: slurmrestd: %v", err)
+		return false
+	}
+	return true
+}
+
+func (s RESTPartitionSource) Read() *PartitionData {
+	data := &PartitionData{}
+	var nodes map[string]*NodeMetrics
+	var jobs []restJob
+	s.get("partitions", func(body []byte) (err error) { data.details, err = ParseRESTPartitions(body); return })
+	hasNodes := s.get("nodes", func(body []byte) (err error) { nodes, err = ParseRESTNodes(body, s.release); return })
+	hasJobs := s.get("jobs", func(body []byte) (err error) { jobs, err = ParseRESTJobs(body); return })
+	if hasNodes && hasJobs {
+		data.partitions = RESTPartitionMetrics(data.details, nodes, jobs)
+	}
+	if hasJobs {
+		data.reqMem = RESTReqMem(jobs)
+	}
+	return data
+}
@@ -0,0 +1,288 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRESTNodes(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/slurmrestd_nodes.json")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	nodes, err := ParseRESTNodes(data, "")
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 3)
+
+	assert.Equal(t, "idle", nodes["gpu01"].nodeStatus)
+	assert.Equal(t, uint64(64), nodes["gpu01"].cpuIdle)
+	assert.Equal(t, 0.02, nodes["gpu01"].cpuLoad)
+	assert.True(t, nodes["gpu01"].hasGPU)
+	assert.Equal(t, uint64(4), nodes["gpu01"].gpuTotal)
+
+	assert.Equal(t, "mixed+drain", nodes["gpu02"].nodeStatus)
+	assert.Equal(t, "mixed", nodes["gpu02"].nodeState)
+	assert.Equal(t, uint64(32), nodes["gpu02"].cpuAlloc)
+	assert.Equal(t, uint64(32), nodes["gpu02"].cpuIdle)
+	assert.Equal(t, 31.8, nodes["gpu02"].cpuLoad)
+	assert.Equal(t, uint64(256000), nodes["gpu02"].memAlloc)
+	assert.Equal(t, uint64(198000), nodes["gpu02"].memFree)
+	assert.Equal(t, []string{"gpu", "long"}, nodes["gpu02"].partitions)
+	assert.Equal(t, []int{1, 0, 0, 1}, nodes["gpu02"].gpus[0].index)
+
+	assert.Equal(t, "down", nodes["cpu01"].nodeState)
+	assert.Equal(t, uint64(128), nodes["cpu01"].cpuOther)
+	assert.False(t, nodes["cpu01"].hasGPU)
+
+	_, err = ParseRESTNodes([]byte(`{"nodes": [], "errors": [{"error": "Unable to query nodes", "description": "denied"}]}`), "")
+	assert.Error(t, err)
+}
+
+func TestRESTNodeSource(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/slurmrestd_nodes.json")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/slurm/v0.0.40/nodes", r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get("X-SLURM-USER-TOKEN"))
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	source := RESTNodeSource{client: NewRESTClient("node", server.URL+"/", "secret", "v0.0.40")}
+	body, err := source.Read()
+	assert.NoError(t, err)
+	nodes, err := source.Parse(body)
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 3)
+}
+
+func TestRESTClientTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	defer func(timeout time.Duration) { *collectorTimeouts["node"] = timeout }(*collectorTimeouts["node"])
+	*collectorTimeouts["node"] = 50 * time.Millisecond
+	_, err := NewRESTClient("node", server.URL, "", "v0.0.40").Get("nodes")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out after 50ms")
+}
+
+func TestNewNodeDataSource(t *testing.T) {
+	source, err := NewNodeDataSource("", "", "", "", "v0.0.40", "")
+	assert.NoError(t, err)
+	assert.IsType(t, ExecNodeSource{}, source)
+
+	// The URL of slurmrestd alone selects the REST API
+	source, err = NewNodeDataSource("", "", "http://slurmctl:6820", "", "v0.0.40", "")
+	assert.NoError(t, err)
+	assert.IsType(t, RESTNodeSource{}, source)
+
+	source, err = NewNodeDataSource("exec", "", "http://slurmctl:6820", "", "v0.0.40", "")
+	assert.NoError(t, err)
+	assert.IsType(t, ExecNodeSource{}, source)
+
+	_, err = NewNodeDataSource("rest", "", "", "", "v0.0.40", "")
+	assert.Error(t, err)
+	_, err = NewNodeDataSource("ssh", "", "", "", "v0.0.40", "")
+	assert.Error(t, err)
+
+	// The format selects the output of sinfo, the REST API is always JSON
+	source, err = NewNodeDataSource("", "json", "", "", "v0.0.40", "")
+	assert.NoError(t, err)
+	assert.IsType(t, JSONNodeSource{}, source)
+	source, err = NewNodeDataSource("exec", "text", "", "", "v0.0.40", "")
+	assert.NoError(t, err)
+	assert.IsType(t, ExecNodeSource{}, source)
+	_, err = NewNodeDataSource("exec", "yaml", "", "", "v0.0.40", "")
+	assert.Error(t, err)
+}
+
+func TestJSONNodeSource(t *testing.T) {
+	calls := stubCommands(t, map[string]string{"sinfo": "test_data/sinfo_nodes.json"})
+	defer func(partition string) { *slurmPartition = partition }(*slurmPartition)
+	*slurmPartition = "gpu"
+
+	source := JSONNodeSource{}
+	data, err := source.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"--json", "-p", "gpu"}, calls["sinfo"])
+	nodes, err := source.Parse(data)
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 2)
+
+	gpu01 := nodes["gpu01"]
+	assert.Equal(t, uint64(16), gpu01.cpuAlloc)
+	assert.Equal(t, uint64(48), gpu01.cpuIdle)
+	assert.Equal(t, 12.5, gpu01.cpuLoad)
+	assert.Equal(t, uint64(300000), gpu01.memFree)
+	assert.Equal(t, uint64(4), gpu01.gpuTotal)
+	assert.Equal(t, uint64(2), gpu01.gpuAlloc)
+	assert.Equal(t, []int{0, 1, 1, 0}, gpu01.gpus[0].index)
+	assert.Equal(t, "a100,nvlink", gpu01.features)
+	assert.Equal(t, []string{"gpu"}, gpu01.partitions)
+
+	// The idle CPUs of a drained node are other CPUs, as with the text output
+	cpu01 := nodes["cpu01"]
+	assert.Equal(t, "drained", cpu01.nodeState)
+	assert.Equal(t, uint64(128), cpu01.cpuOther)
+	assert.Equal(t, uint64(0), cpu01.gpuTotal)
+
+	_, err = source.Parse([]byte(`{"nodes": [], "errors": [{"error": "Unable to contact slurm controller", "description": ""}]}`))
+	assert.Contains(t, err.Error(), "sinfo --json")
+}
+
+func TestParseRESTJobs(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/slurmrestd_jobs.json")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	jobs, err := ParseRESTJobs(data)
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 3)
+
+	// One hour after the start of 4201
+	details := RESTJobDetails(jobs, time.Unix(1710141000, 0))
+	assert.Equal(t, &JobDetails{state: "running", partition: "gpu", user: "alice", cpus: 32, nodes: 1, timeUsed: 3600, reason: "None"}, details["4201"])
+	assert.Equal(t, 0.0, details["4202"].timeUsed)
+	assert.Equal(t, map[string]float64{"Dependency": 1, "Priority": 1}, PendingJobsByReason(details))
+
+	// Wrapped and plain numbers end up in the same queue metrics
+	qm := QueueJobsMetrics(RESTQueueJobs(jobs))
+	assert.Equal(t, NVal{"alice": {"gpu": 32}}, qm.c_running)
+	assert.Equal(t, 1.0, qm.dependency)
+	assert.Equal(t, 1.0, qm.dependency_chains)
+	assert.Equal(t, time.Unix(1710140400, 0), qm.oldest_pending)
+	assert.Equal(t, NVal{"1": {"running": 1, "pending": 1}, "2-4": {"pending": 1}}, qm.jobs_by_nodes)
+
+	// Jobs without a node count are not bucketed, like in the output of squeue
+	unset, err := ParseRESTJobs([]byte(`{"jobs": [
+		{"job_id": 4301, "job_state": ["PENDING"], "partition": "cpu", "user_name": "bob", "node_count": {"set": false, "infinite": false, "number": 0}},
+		{"job_id": 4302, "job_state": ["PENDING"], "partition": "cpu", "user_name": "bob"}]}`))
+	assert.NoError(t, err)
+	queue := RESTQueueJobs(unset)
+	assert.Equal(t, -1, queue[0].nodes)
+	assert.Equal(t, -1, queue[1].nodes)
+	assert.Empty(t, QueueJobsMetrics(queue).jobs_by_nodes)
+
+	_, err = ParseRESTJobs([]byte(`{"jobs": [], "errors": [{"error": "Unable to query jobs", "description": "denied"}]}`))
+	assert.Error(t, err)
+}
+
+func TestRESTPartitions(t *testing.T) {
+	read := func(file string) []byte {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Can not open test data: %v", err)
+		}
+		return data
+	}
+	details, err := ParseRESTPartitions(read("test_data/slurmrestd_partitions.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"gpu": 1, "long": 0, "debug": 0}, PartitionDefaults(details))
+	// The state of older API versions is no part of "partition"
+	assert.Equal(t, map[string]float64{"gpu": 1, "long": 0, "debug": 1}, PartitionStates(details))
+
+	nodes, err := ParseRESTNodes(read("test_data/slurmrestd_nodes.json"), "")
+	assert.NoError(t, err)
+	jobs, err := ParseRESTJobs(read("test_data/slurmrestd_jobs.json"))
+	assert.NoError(t, err)
+	partitions := RESTPartitionMetrics(details, nodes, jobs)
+
+	// gpu02 is in gpu and long, the pending job 4202 in both
+	assert.Equal(t, &PartitionMetrics{allocated: 32, idle: 32, other: 0, pending: 1, total: 64, nodes: 1}, partitions["gpu"])
+	assert.Equal(t, 2.0, partitions["long"].pending)
+	assert.Equal(t, &PartitionMetrics{}, partitions["debug"])
+
+	// 4202 requests 16 CPUs of 4000 MB each
+	reqMem := RESTReqMem(jobs)
+	assert.Equal(t, uint64(64000), reqMem["gpu"].max)
+	assert.Equal(t, 64000.0, reqMem["gpu"].Avg())
+	assert.Equal(t, uint64(2), reqMem["long"].jobs)
+	assert.Equal(t, 36000.0, reqMem["long"].Avg())
+
+	// Jobs without a memory request are left out
+	assert.Empty(t, RESTReqMem([]restJob{{Partition: "debug", CPUs: 4}}))
+}
+
+func TestRESTPartitionSource(t *testing.T) {
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		files := map[string]string{
+			"/slurm/v0.0.40/nodes":      "test_data/slurmrestd_nodes.json",
+			"/slurm/v0.0.40/jobs":       "test_data/slurmrestd_jobs.json",
+			"/slurm/v0.0.40/partitions": "test_data/slurmrestd_partitions.json",
+		}
+		data, err := ioutil.ReadFile(files[r.URL.Path])
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	source, err := NewPartitionDataSource("rest", server.URL, "", "v0.0.40", "")
+	assert.NoError(t, err)
+	data := source.Read()
+	assert.Len(t, data.partitions, 3)
+	assert.Equal(t, "YES", data.details["gpu"]["Default"])
+	assert.Len(t, data.reqMem, 2)
+	// Every endpoint once per scrape
+	assert.Equal(t, map[string]int{"/slurm/v0.0.40/nodes": 1, "/slurm/v0.0.40/jobs": 1, "/slurm/v0.0.40/partitions": 1}, requests)
+
+	jobSource, err := NewJobDataSource("job", "rest", server.URL, "", "v0.0.40")
+	assert.NoError(t, err)
+	assert.Len(t, jobSource.Jobs(), 3)
+	assert.Len(t, jobSource.QueueJobs(), 3)
+
+	// A failing slurmrestd is logged, the collectors report nothing
+	server.Close()
+	assert.Empty(t, source.Read().partitions)
+	assert.Empty(t, jobSource.Jobs())
+}
+
+func TestCollectorSource(t *testing.T) {
+	defer func(source string) { *slurmSource = source }(*slurmSource)
+	*slurmSource = "rest"
+	defer func(source string) { *collectorSources["queue"] = source }(*collectorSources["queue"])
+	*collectorSources["queue"] = "exec"
+
+	assert.Equal(t, "rest", CollectorSource("node"))
+	assert.Equal(t, "exec", CollectorSource("queue"))
+
+	source, err := NewJobDataSource("queue", CollectorSource("queue"), "http://slurmctl:6820", "", "v0.0.40")
+	assert.NoError(t, err)
+	assert.IsType(t, ExecJobSource{}, source)
+	source, err = NewJobDataSource("job", CollectorSource("job"), "http://slurmctl:6820", "", "v0.0.40")
+	assert.NoError(t, err)
+	assert.IsType(t, RESTJobSource{}, source)
+	_, err = NewPartitionDataSource("rest", "", "", "v0.0.40", "")
+	assert.Error(t, err)
+}
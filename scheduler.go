@@ -16,14 +16,11 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>. */
 package main
 
 import (
-	"io/ioutil"
-	"os/exec"
 	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/common/log"
 )
 
 /*
@@ -35,17 +32,29 @@ import (
 // Basic metrics for the scheduler
 type SchedulerMetrics struct {
 	threads                           float64
+	server_thread_count               float64
+	agent_count                       float64
+	agent_thread_count                float64
 	queue_size                        float64
 	dbd_queue_size                    float64
-	last_cycle                        float64
-	mean_cycle                        float64
 	cycle_per_minute                  float64
 	backfill_last_cycle               float64
 	backfill_mean_cycle               float64
 	backfill_depth_mean               float64
+	main_last_cycle                   float64
+	main_mean_cycle                   float64
+	main_max_cycle                    float64
+	backfill_max_cycle                float64
 	total_backfilled_jobs_since_start float64
 	total_backfilled_jobs_since_cycle float64
 	total_backfilled_heterogeneous    float64
+	jobs_submitted                    float64
+	jobs_started                      float64
+	jobs_completed                    float64
+	jobs_canceled                     float64
+	jobs_failed                       float64
+	main_total_cycles                 float64
+	backfill_total_cycles             float64
 	rpc_stats_count                   map[string]float64
 	rpc_stats_avg_time                map[string]float64
 	rpc_stats_total_time              map[string]float64
@@ -56,65 +65,65 @@ type SchedulerMetrics struct {
 
 // Execute the sdiag command and return its output
 func SchedulerData() []byte {
-	cmd := exec.Command("sdiag")
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		log.Fatal(err)
-	}
-	if err := cmd.Start(); err != nil {
-		log.Fatal(err)
-	}
-	out, _ := ioutil.ReadAll(stdout)
-	if err := cmd.Wait(); err != nil {
-		log.Fatal(err)
-	}
-	return out
+	return CollectorData("scheduler", "sdiag")
 }
 
 // Extract the relevant metrics from the sdiag output
 func ParseSchedulerMetrics(input []byte) *SchedulerMetrics {
 	var sm SchedulerMetrics
 	lines := strings.Split(string(input), "\n")
-	// Guard variables to check for string repetitions in the output of sdiag
-	// (two occurencies of the following strings: 'Last cycle', 'Mean cycle')
-	lc_count := 0
-	mc_count := 0
+	// The cycle statistics ('Last cycle', 'Max cycle', 'Mean cycle') appear
+	// in both the main schedule and the backfilling section of sdiag
+	section := ""
 	for _, line := range lines {
+		if strings.HasPrefix(line, "Main schedule statistics") {
+			section = "main"
+		} else if strings.HasPrefix(line, "Backfilling stats") {
+			section = "backfill"
+		} else if line != "" && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			section = ""
+		}
 		if strings.Contains(line, ":") {
 			state := strings.Split(line, ":")[0]
 			st := regexp.MustCompile(`^Server thread`)
 			qs := regexp.MustCompile(`^Agent queue`)
+			ac := regexp.MustCompile(`^Agent count`)
+			atc := regexp.MustCompile(`^Agent thread count`)
 			dbd := regexp.MustCompile(`^DBD Agent`)
 			lc := regexp.MustCompile(`^[\s]+Last cycle$`)
 			mc := regexp.MustCompile(`^[\s]+Mean cycle$`)
+			xc := regexp.MustCompile(`^[\s]+Max cycle$`)
 			cpm := regexp.MustCompile(`^[\s]+Cycles per`)
 			dpm := regexp.MustCompile(`^[\s]+Depth Mean$`)
 			tbs := regexp.MustCompile(`^[\s]+Total backfilled jobs \(since last slurm start\)`)
 			tbc := regexp.MustCompile(`^[\s]+Total backfilled jobs \(since last stats cycle start\)`)
 			tbh := regexp.MustCompile(`^[\s]+Total backfilled heterogeneous job components`)
+			tc := regexp.MustCompile(`^[\s]+Total cycles$`)
+			js := regexp.MustCompile(`^Jobs (submitted|started|completed|canceled|failed)$`)
 			switch {
 			case st.MatchString(state):
 				sm.threads, _ = strconv.ParseFloat(strings.TrimSpace(strings.Split(line, ":")[1]), 64)
+				sm.server_thread_count = sm.threads
+			case ac.MatchString(state):
+				sm.agent_count = SplitColonValueToFloat(line)
+			case atc.MatchString(state):
+				sm.agent_thread_count = SplitColonValueToFloat(line)
 			case qs.MatchString(state):
 				sm.queue_size, _ = strconv.ParseFloat(strings.TrimSpace(strings.Split(line, ":")[1]), 64)
 			case dbd.MatchString(state):
 				sm.dbd_queue_size, _ = strconv.ParseFloat(strings.TrimSpace(strings.Split(line, ":")[1]), 64)
-			case lc.MatchString(state):
-				if lc_count == 0 {
-					sm.last_cycle, _ = strconv.ParseFloat(strings.TrimSpace(strings.Split(line, ":")[1]), 64)
-					lc_count = 1
-				}
-				if lc_count == 1 {
-					sm.backfill_last_cycle, _ = strconv.ParseFloat(strings.TrimSpace(strings.Split(line, ":")[1]), 64)
-				}
-			case mc.MatchString(state):
-				if mc_count == 0 {
-					sm.mean_cycle, _ = strconv.ParseFloat(strings.TrimSpace(strings.Split(line, ":")[1]), 64)
-					mc_count = 1
-				}
-				if mc_count == 1 {
-					sm.backfill_mean_cycle, _ = strconv.ParseFloat(strings.TrimSpace(strings.Split(line, ":")[1]), 64)
-				}
+			case lc.MatchString(state) && section == "main":
+				sm.main_last_cycle = SplitColonValueToFloat(line)
+			case lc.MatchString(state) && section == "backfill":
+				sm.backfill_last_cycle = SplitColonValueToFloat(line)
+			case mc.MatchString(state) && section == "main":
+				sm.main_mean_cycle = SplitColonValueToFloat(line)
+			case mc.MatchString(state) && section == "backfill":
+				sm.backfill_mean_cycle = SplitColonValueToFloat(line)
+			case xc.MatchString(state) && section == "main":
+				sm.main_max_cycle = SplitColonValueToFloat(line)
+			case xc.MatchString(state) && section == "backfill":
+				sm.backfill_max_cycle = SplitColonValueToFloat(line)
 			case cpm.MatchString(state):
 				sm.cycle_per_minute, _ = strconv.ParseFloat(strings.TrimSpace(strings.Split(line, ":")[1]), 64)
 			case dpm.MatchString(state):
@@ -125,6 +134,23 @@ func ParseSchedulerMetrics(input []byte) *SchedulerMetrics {
 				sm.total_backfilled_jobs_since_cycle, _ = strconv.ParseFloat(strings.TrimSpace(strings.Split(line, ":")[1]), 64)
 			case tbh.MatchString(state):
 				sm.total_backfilled_heterogeneous, _ = strconv.ParseFloat(strings.TrimSpace(strings.Split(line, ":")[1]), 64)
+			case tc.MatchString(state) && section == "main":
+				sm.main_total_cycles = SplitColonValueToFloat(line)
+			case tc.MatchString(state) && section == "backfill":
+				sm.backfill_total_cycles = SplitColonValueToFloat(line)
+			case js.MatchString(state):
+				switch js.FindStringSubmatch(state)[1] {
+				case "submitted":
+					sm.jobs_submitted = SplitColonValueToFloat(line)
+				case "started":
+					sm.jobs_started = SplitColonValueToFloat(line)
+				case "completed":
+					sm.jobs_completed = SplitColonValueToFloat(line)
+				case "canceled":
+					sm.jobs_canceled = SplitColonValueToFloat(line)
+				case "failed":
+					sm.jobs_failed = SplitColonValueToFloat(line)
+				}
 			}
 		}
 	}
@@ -223,6 +249,9 @@ func SchedulerGetMetrics() *SchedulerMetrics {
 // Collector strcture
 type SchedulerCollector struct {
 	threads                           *prometheus.Desc
+	server_thread_count               *prometheus.Desc
+	agent_count                       *prometheus.Desc
+	agent_thread_count                *prometheus.Desc
 	queue_size                        *prometheus.Desc
 	dbd_queue_size                    *prometheus.Desc
 	last_cycle                        *prometheus.Desc
@@ -231,9 +260,22 @@ type SchedulerCollector struct {
 	backfill_last_cycle               *prometheus.Desc
 	backfill_mean_cycle               *prometheus.Desc
 	backfill_depth_mean               *prometheus.Desc
+	main_last_cycle_useconds          *prometheus.Desc
+	main_mean_cycle_useconds          *prometheus.Desc
+	main_max_cycle_useconds           *prometheus.Desc
+	backfill_last_cycle_useconds      *prometheus.Desc
+	backfill_mean_cycle_useconds      *prometheus.Desc
+	backfill_max_cycle_useconds       *prometheus.Desc
 	total_backfilled_jobs_since_start *prometheus.Desc
 	total_backfilled_jobs_since_cycle *prometheus.Desc
 	total_backfilled_heterogeneous    *prometheus.Desc
+	jobs_submitted                    *prometheus.Desc
+	jobs_started                      *prometheus.Desc
+	jobs_completed                    *prometheus.Desc
+	jobs_canceled                     *prometheus.Desc
+	jobs_failed                       *prometheus.Desc
+	main_total_cycles                 *prometheus.Desc
+	backfill_total_cycles             *prometheus.Desc
 	rpc_stats_count                   *prometheus.Desc
 	rpc_stats_avg_time                *prometheus.Desc
 	rpc_stats_total_time              *prometheus.Desc
@@ -245,6 +287,9 @@ type SchedulerCollector struct {
 // Send all metric descriptions
 func (c *SchedulerCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.threads
+	ch <- c.server_thread_count
+	ch <- c.agent_count
+	ch <- c.agent_thread_count
 	ch <- c.queue_size
 	ch <- c.dbd_queue_size
 	ch <- c.last_cycle
@@ -253,9 +298,22 @@ func (c *SchedulerCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.backfill_last_cycle
 	ch <- c.backfill_mean_cycle
 	ch <- c.backfill_depth_mean
+	ch <- c.main_last_cycle_useconds
+	ch <- c.main_mean_cycle_useconds
+	ch <- c.main_max_cycle_useconds
+	ch <- c.backfill_last_cycle_useconds
+	ch <- c.backfill_mean_cycle_useconds
+	ch <- c.backfill_max_cycle_useconds
 	ch <- c.total_backfilled_jobs_since_start
 	ch <- c.total_backfilled_jobs_since_cycle
 	ch <- c.total_backfilled_heterogeneous
+	ch <- c.jobs_submitted
+	ch <- c.jobs_started
+	ch <- c.jobs_completed
+	ch <- c.jobs_canceled
+	ch <- c.jobs_failed
+	ch <- c.main_total_cycles
+	ch <- c.backfill_total_cycles
 	ch <- c.rpc_stats_count
 	ch <- c.rpc_stats_avg_time
 	ch <- c.rpc_stats_total_time
@@ -268,17 +326,35 @@ func (c *SchedulerCollector) Describe(ch chan<- *prometheus.Desc) {
 func (sc *SchedulerCollector) Collect(ch chan<- prometheus.Metric) {
 	sm := SchedulerGetMetrics()
 	ch <- prometheus.MustNewConstMetric(sc.threads, prometheus.GaugeValue, sm.threads)
+	ch <- prometheus.MustNewConstMetric(sc.server_thread_count, prometheus.GaugeValue, sm.server_thread_count)
+	ch <- prometheus.MustNewConstMetric(sc.agent_count, prometheus.GaugeValue, sm.agent_count)
+	ch <- prometheus.MustNewConstMetric(sc.agent_thread_count, prometheus.GaugeValue, sm.agent_thread_count)
 	ch <- prometheus.MustNewConstMetric(sc.queue_size, prometheus.GaugeValue, sm.queue_size)
 	ch <- prometheus.MustNewConstMetric(sc.dbd_queue_size, prometheus.GaugeValue, sm.dbd_queue_size)
-	ch <- prometheus.MustNewConstMetric(sc.last_cycle, prometheus.GaugeValue, sm.last_cycle)
-	ch <- prometheus.MustNewConstMetric(sc.mean_cycle, prometheus.GaugeValue, sm.mean_cycle)
+	// Deprecated aliases of slurm_scheduler_main_{last,mean}_cycle_useconds
+	ch <- prometheus.MustNewConstMetric(sc.last_cycle, prometheus.GaugeValue, sm.main_last_cycle)
+	ch <- prometheus.MustNewConstMetric(sc.mean_cycle, prometheus.GaugeValue, sm.main_mean_cycle)
 	ch <- prometheus.MustNewConstMetric(sc.cycle_per_minute, prometheus.GaugeValue, sm.cycle_per_minute)
+	// Deprecated aliases of slurm_scheduler_backfill_{last,mean}_cycle_useconds
 	ch <- prometheus.MustNewConstMetric(sc.backfill_last_cycle, prometheus.GaugeValue, sm.backfill_last_cycle)
 	ch <- prometheus.MustNewConstMetric(sc.backfill_mean_cycle, prometheus.GaugeValue, sm.backfill_mean_cycle)
 	ch <- prometheus.MustNewConstMetric(sc.backfill_depth_mean, prometheus.GaugeValue, sm.backfill_depth_mean)
+	ch <- prometheus.MustNewConstMetric(sc.main_last_cycle_useconds, prometheus.GaugeValue, sm.main_last_cycle)
+	ch <- prometheus.MustNewConstMetric(sc.main_mean_cycle_useconds, prometheus.GaugeValue, sm.main_mean_cycle)
+	ch <- prometheus.MustNewConstMetric(sc.main_max_cycle_useconds, prometheus.GaugeValue, sm.main_max_cycle)
+	ch <- prometheus.MustNewConstMetric(sc.backfill_last_cycle_useconds, prometheus.GaugeValue, sm.backfill_last_cycle)
+	ch <- prometheus.MustNewConstMetric(sc.backfill_mean_cycle_useconds, prometheus.GaugeValue, sm.backfill_mean_cycle)
+	ch <- prometheus.MustNewConstMetric(sc.backfill_max_cycle_useconds, prometheus.GaugeValue, sm.backfill_max_cycle)
 	ch <- prometheus.MustNewConstMetric(sc.total_backfilled_jobs_since_start, prometheus.GaugeValue, sm.total_backfilled_jobs_since_start)
 	ch <- prometheus.MustNewConstMetric(sc.total_backfilled_jobs_since_cycle, prometheus.GaugeValue, sm.total_backfilled_jobs_since_cycle)
 	ch <- prometheus.MustNewConstMetric(sc.total_backfilled_heterogeneous, prometheus.GaugeValue, sm.total_backfilled_heterogeneous)
+	ch <- prometheus.MustNewConstMetric(sc.jobs_submitted, prometheus.CounterValue, sm.jobs_submitted)
+	ch <- prometheus.MustNewConstMetric(sc.jobs_started, prometheus.CounterValue, sm.jobs_started)
+	ch <- prometheus.MustNewConstMetric(sc.jobs_completed, prometheus.CounterValue, sm.jobs_completed)
+	ch <- prometheus.MustNewConstMetric(sc.jobs_canceled, prometheus.CounterValue, sm.jobs_canceled)
+	ch <- prometheus.MustNewConstMetric(sc.jobs_failed, prometheus.CounterValue, sm.jobs_failed)
+	ch <- prometheus.MustNewConstMetric(sc.main_total_cycles, prometheus.CounterValue, sm.main_total_cycles)
+	ch <- prometheus.MustNewConstMetric(sc.backfill_total_cycles, prometheus.CounterValue, sm.backfill_total_cycles)
 	for rpc_type, value := range sm.rpc_stats_count {
 		ch <- prometheus.MustNewConstMetric(sc.rpc_stats_count, prometheus.GaugeValue, value, rpc_type)
 	}
@@ -312,6 +388,21 @@ func NewSchedulerCollector() *SchedulerCollector {
 			"Information provided by the Slurm sdiag command, number of scheduler threads ",
 			nil,
 			nil),
+		server_thread_count: prometheus.NewDesc(
+			"slurm_scheduler_server_thread_count",
+			"Information provided by the Slurm sdiag command, number of active slurmctld server threads",
+			nil,
+			nil),
+		agent_count: prometheus.NewDesc(
+			"slurm_scheduler_agent_count",
+			"Information provided by the Slurm sdiag command, number of active agents sending RPCs to the nodes",
+			nil,
+			nil),
+		agent_thread_count: prometheus.NewDesc(
+			"slurm_scheduler_agent_thread_count",
+			"Information provided by the Slurm sdiag command, number of threads of the active agents",
+			nil,
+			nil),
 		queue_size: prometheus.NewDesc(
 			"slurm_scheduler_queue_size",
 			"Information provided by the Slurm sdiag command, length of the scheduler queue",
@@ -352,6 +443,36 @@ func NewSchedulerCollector() *SchedulerCollector {
 			"Information provided by the Slurm sdiag command, scheduler backfill mean depth",
 			nil,
 			nil),
+		main_last_cycle_useconds: prometheus.NewDesc(
+			"slurm_scheduler_main_last_cycle_useconds",
+			"Information provided by the Slurm sdiag command, main scheduler last cycle time in microseconds",
+			nil,
+			nil),
+		main_mean_cycle_useconds: prometheus.NewDesc(
+			"slurm_scheduler_main_mean_cycle_useconds",
+			"Information provided by the Slurm sdiag command, main scheduler mean cycle time in microseconds",
+			nil,
+			nil),
+		main_max_cycle_useconds: prometheus.NewDesc(
+			"slurm_scheduler_main_max_cycle_useconds",
+			"Information provided by the Slurm sdiag command, main scheduler max cycle time in microseconds",
+			nil,
+			nil),
+		backfill_last_cycle_useconds: prometheus.NewDesc(
+			"slurm_scheduler_backfill_last_cycle_useconds",
+			"Information provided by the Slurm sdiag command, backfill scheduler last cycle time in microseconds",
+			nil,
+			nil),
+		backfill_mean_cycle_useconds: prometheus.NewDesc(
+			"slurm_scheduler_backfill_mean_cycle_useconds",
+			"Information provided by the Slurm sdiag command, backfill scheduler mean cycle time in microseconds",
+			nil,
+			nil),
+		backfill_max_cycle_useconds: prometheus.NewDesc(
+			"slurm_scheduler_backfill_max_cycle_useconds",
+			"Information provided by the Slurm sdiag command, backfill scheduler max cycle time in microseconds",
+			nil,
+			nil),
 		total_backfilled_jobs_since_start: prometheus.NewDesc(
 			"slurm_scheduler_backfilled_jobs_since_start_total",
 			"Information provided by the Slurm sdiag command, number of jobs started thanks to backfilling since last slurm start",
@@ -367,6 +488,41 @@ func NewSchedulerCollector() *SchedulerCollector {
 			"Information provided by the Slurm sdiag command, number of heterogeneous job components started thanks to backfilling since last Slurm start",
 			nil,
 			nil),
+		jobs_submitted: prometheus.NewDesc(
+			"slurm_scheduler_jobs_submitted_total",
+			"Information provided by the Slurm sdiag command, number of jobs submitted since last time stats where reset",
+			nil,
+			nil),
+		jobs_started: prometheus.NewDesc(
+			"slurm_scheduler_jobs_started_total",
+			"Information provided by the Slurm sdiag command, number of jobs started since last time stats where reset",
+			nil,
+			nil),
+		jobs_completed: prometheus.NewDesc(
+			"slurm_scheduler_jobs_completed_total",
+			"Information provided by the Slurm sdiag command, number of jobs completed since last time stats where reset",
+			nil,
+			nil),
+		jobs_canceled: prometheus.NewDesc(
+			"slurm_scheduler_jobs_canceled_total",
+			"Information provided by the Slurm sdiag command, number of jobs canceled since last time stats where reset",
+			nil,
+			nil),
+		jobs_failed: prometheus.NewDesc(
+			"slurm_scheduler_jobs_failed_total",
+			"Information provided by the Slurm sdiag command, number of jobs failed since last time stats where reset",
+			nil,
+			nil),
+		main_total_cycles: prometheus.NewDesc(
+			"slurm_scheduler_main_cycles_total",
+			"Information provided by the Slurm sdiag command, number of main scheduler cycles since last time stats where reset",
+			nil,
+			nil),
+		backfill_total_cycles: prometheus.NewDesc(
+			"slurm_scheduler_backfill_cycles_total",
+			"Information provided by the Slurm sdiag command, number of backfill scheduler cycles since last time stats where reset",
+			nil,
+			nil),
 		rpc_stats_count: prometheus.NewDesc(
 			"slurm_rpc_stats",
 			"Information provided by the Slurm sdiag command, rpc count statistic",
@@ -19,6 +19,10 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestSchedulerMetrics(t *testing.T) {
@@ -30,3 +34,68 @@ func TestSchedulerMetrics(t *testing.T) {
 	data, err := ioutil.ReadAll(file)
 	t.Logf("%+v", ParseSchedulerMetrics(data))
 }
+
+func TestSchedulerCycles(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sdiag.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	sm := ParseSchedulerMetrics(data)
+
+	assert.Equal(t, 97209.0, sm.main_last_cycle)
+	assert.Equal(t, 74593.0, sm.main_mean_cycle)
+	assert.Equal(t, 1407590.0, sm.main_max_cycle)
+	assert.Equal(t, 1942890.0, sm.backfill_last_cycle)
+	assert.Equal(t, 1960820.0, sm.backfill_mean_cycle)
+	assert.Equal(t, 5933334.0, sm.backfill_max_cycle)
+}
+
+func TestSchedulerThreads(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sdiag_agents.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	sm := ParseSchedulerMetrics(data)
+
+	assert.Equal(t, 12.0, sm.server_thread_count)
+	assert.Equal(t, 4.0, sm.queue_size)
+	assert.Equal(t, 2.0, sm.agent_count)
+	assert.Equal(t, 6.0, sm.agent_thread_count)
+
+	// The former metric keeps its value
+	assert.Equal(t, sm.server_thread_count, sm.threads)
+}
+
+func TestSchedulerJobs(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sdiag.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	sm := ParseSchedulerMetrics(data)
+
+	assert.Equal(t, 9706.0, sm.jobs_submitted)
+	assert.Equal(t, 35395.0, sm.jobs_started)
+	assert.Equal(t, 31254.0, sm.jobs_completed)
+	assert.Equal(t, 2835.0, sm.jobs_canceled)
+	assert.Equal(t, 0.0, sm.jobs_failed)
+	// "Total cycles" of both sections
+	assert.Equal(t, 34585.0, sm.main_total_cycles)
+	assert.Equal(t, 529.0, sm.backfill_total_cycles)
+}
+
+func TestSchedulerCounters(t *testing.T) {
+	stubCommands(t, map[string]string{"sdiag": "test_data/sdiag.txt"})
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(NewSchedulerCollector())
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+
+	types := make(map[string]dto.MetricType)
+	for _, family := range families {
+		types[family.GetName()] = family.GetType()
+	}
+	for _, name := range []string{"jobs_submitted", "jobs_started", "jobs_completed", "jobs_canceled", "jobs_failed", "main_cycles", "backfill_cycles"} {
+		assert.Equal(t, dto.MetricType_COUNTER, types["slurm_scheduler_"+name+"_total"], name)
+	}
+	assert.Equal(t, dto.MetricType_GAUGE, types["slurm_scheduler_queue_size"])
+}
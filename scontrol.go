@@ -0,0 +1,75 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"strings"
+)
+
+// ParseScontrolRecord splits a single record of the scontrol one-liner
+// output (-o) into its Key=Value pairs. Values may contain spaces,
+// e.g. "Reason=Not responding [slurm@2024-03-11T09:12:45]", any word
+// without an equal sign is appended to the value of the previous key.
+func ParseScontrolRecord(line string) map[string]string {
+	record := make(map[string]string)
+	key := ""
+	for _, word := range strings.Fields(line) {
+		kv := strings.SplitN(word, "=", 2)
+		if len(kv) == 2 {
+			key = kv[0]
+			record[key] = kv[1]
+		} else if key != "" {
+			record[key] += " " + word
+		}
+	}
+	return record
+}
+
+// ScontrolNodesData executes scontrol on behalf of a collector to get the details of every node, one line per node
+func ScontrolNodesData(collector string) []byte {
+	return CollectorData(collector, "scontrol", "show", "nodes", "-o")
+}
+
+// ParseScontrolNodes takes the output of scontrol show nodes -o
+// It returns the Key=Value pairs of every node, keyed by NodeName
+func ParseScontrolNodes(input []byte) map[string]map[string]string {
+	nodes := make(map[string]map[string]string)
+	for _, line := range strings.Split(string(input), "\n") {
+		record := ParseScontrolRecord(line)
+		if name, ok := record["NodeName"]; ok {
+			nodes[name] = record
+		}
+	}
+	return nodes
+}
+
+// ScontrolPartitionsData executes scontrol on behalf of a collector to get the details of every partition, one line per partition
+func ScontrolPartitionsData(collector string) []byte {
+	return CollectorData(collector, "scontrol", "show", "partition", "-o")
+}
+
+// ParseScontrolPartitions takes the output of scontrol show partition -o
+// It returns the Key=Value pairs of every partition, keyed by PartitionName
+func ParseScontrolPartitions(input []byte) map[string]map[string]string {
+	partitions := make(map[string]map[string]string)
+	for _, line := range strings.Split(string(input), "\n") {
+		record := ParseScontrolRecord(line)
+		if name, ok := record["PartitionName"]; ok {
+			partitions[name] = record
+		}
+	}
+	return partitions
+}
@@ -0,0 +1,37 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseScontrolNodes(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/scontrol_nodes.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	nodes := ParseScontrolNodes(data)
+
+	assert.Len(t, nodes, 3)
+	assert.Equal(t, "MIXED+DRAIN", nodes["gpu02"]["State"])
+	assert.Equal(t, "Linux 5.14.0-284.el9.x86_64 #1 SMP", nodes["gpu02"]["OS"])
+	assert.Equal(t, "Bad GPU [root@2024-03-11T09:12:45]", nodes["gpu02"]["Reason"])
+	assert.Equal(t, "(null)", nodes["cpu01"]["Gres"])
+}
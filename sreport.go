@@ -0,0 +1,156 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Utilization of a cluster in percent of the reported time
+type ReportMetrics struct {
+	allocated float64
+	down      float64
+	idle      float64
+	reserved  float64
+}
+
+// Execute the sreport command for the utilization of the previous day
+func ReportData() []byte {
+	return CollectorData("sreport", "sreport", "-t", "percent", "cluster", "utilization")
+}
+
+// ParseReportUtilization takes the tabular output of sreport cluster utilization -t percent
+// The columns are located by the dashes below the header, as their names contain spaces.
+// Slurm before 23.02 reports the reserved time in a Reserved instead of a Planned column.
+func ParseReportUtilization(input []byte) map[string]*ReportMetrics {
+	clusters := make(map[string]*ReportMetrics)
+	lines := strings.Split(string(input), "\n")
+	for i := 1; i < len(lines); i++ {
+		if !strings.HasPrefix(lines[i], "---") || !strings.Contains(strings.TrimSpace(lines[i]), " ") {
+			continue
+		}
+		// End of every column from the separator line, a column
+		// reaches from the end of the previous one to its own end
+		var ends []int
+		for j, c := range lines[i] + " " {
+			if c == ' ' && j > 0 && lines[i][j-1] == '-' {
+				ends = append(ends, j)
+			}
+		}
+		column := func(line string, n int) string {
+			from, to := 0, len(line)
+			if n > 0 {
+				from = ends[n-1]
+			}
+			if n+1 < len(ends) && ends[n] < to {
+				to = ends[n]
+			}
+			if from >= to {
+				return ""
+			}
+			return strings.TrimSpace(line[from:to])
+		}
+		header := make(map[string]int)
+		for n := range ends {
+			header[column(lines[i-1], n)] = n
+		}
+		for _, line := range lines[i+1:] {
+			name := column(line, 0)
+			if name == "" {
+				continue
+			}
+			percent := func(field string) float64 {
+				n, ok := header[field]
+				if !ok {
+					return 0
+				}
+				value, _ := strconv.ParseFloat(strings.TrimSuffix(column(line, n), "%"), 64)
+				return value
+			}
+			clusters[name] = &ReportMetrics{
+				allocated: percent("Allocated"),
+				down:      percent("Down") + percent("PLND Down"),
+				idle:      percent("Idle"),
+				reserved:  percent("Planned") + percent("Reserved"),
+			}
+		}
+		break
+	}
+	return clusters
+}
+
+/*
+ * Implement the Prometheus Collector interface and feed the
+ * Slurm report metrics into it.
+ * https://godoc.org/github.com/prometheus/client_golang/prometheus#Collector
+ */
+
+func NewReportCollector(interval time.Duration) *ReportCollector {
+	labels := []string{"cluster"}
+	return &ReportCollector{
+		interval:    interval,
+		utilization: prometheus.NewDesc("slurm_report_utilization_percent", "Allocated time of the cluster in percent of the reported time of the previous day", labels, nil),
+		down:        prometheus.NewDesc("slurm_report_down_percent", "Down time, including planned down time, in percent of the reported time of the previous day", labels, nil),
+		idle:        prometheus.NewDesc("slurm_report_idle_percent", "Idle time in percent of the reported time of the previous day", labels, nil),
+		reserved:    prometheus.NewDesc("slurm_report_reserved_percent", "Reserved time in percent of the reported time of the previous day", labels, nil),
+	}
+}
+
+// sreport is expensive and its numbers change once a day, the collector
+// only executes it once per interval and exports the cached result otherwise
+type ReportCollector struct {
+	interval time.Duration
+	mutex    sync.Mutex
+	lastRun  time.Time
+	clusters map[string]*ReportMetrics
+
+	utilization *prometheus.Desc
+	down        *prometheus.Desc
+	idle        *prometheus.Desc
+	reserved    *prometheus.Desc
+}
+
+// Send all metric descriptions
+func (rc *ReportCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- rc.utilization
+	ch <- rc.down
+	ch <- rc.idle
+	ch <- rc.reserved
+}
+
+func (rc *ReportCollector) Collect(ch chan<- prometheus.Metric) {
+	rc.mutex.Lock()
+	if rc.clusters == nil || time.Since(rc.lastRun) >= rc.interval {
+		// A failed run is retried on the next scrape
+		if clusters := ParseReportUtilization(ReportData()); len(clusters) > 0 {
+			rc.clusters = clusters
+			rc.lastRun = time.Now()
+		}
+	}
+	clusters := rc.clusters
+	rc.mutex.Unlock()
+	for cluster, rm := range clusters {
+		ch <- prometheus.MustNewConstMetric(rc.utilization, prometheus.GaugeValue, rm.allocated, cluster)
+		ch <- prometheus.MustNewConstMetric(rc.down, prometheus.GaugeValue, rm.down, cluster)
+		ch <- prometheus.MustNewConstMetric(rc.idle, prometheus.GaugeValue, rm.idle, cluster)
+		ch <- prometheus.MustNewConstMetric(rc.reserved, prometheus.GaugeValue, rm.reserved, cluster)
+	}
+}
@@ -0,0 +1,44 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReportUtilization(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sreport_utilization.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	clusters := ParseReportUtilization(data)
+
+	assert.Len(t, clusters, 1)
+	assert.Equal(t, &ReportMetrics{allocated: 75.32, down: 2.10, idle: 20.08, reserved: 2.50}, clusters["hpc"])
+
+	// Slurm before 23.02 reports Reserved instead of Planned
+	old := []byte(`  Cluster      Allocated          Down     PLND Down          Idle   Reserved Reported 
+--------- -------------- ------------- ------------- ------------- ---------- -------- 
+   legacy        50.00%         1.00%         1.00%        40.00%      8.00%  100.00% 
+`)
+	assert.Equal(t, &ReportMetrics{allocated: 50, down: 2, idle: 40, reserved: 8}, ParseReportUtilization(old)["legacy"])
+
+	// No data, e.g. sreport failed
+	assert.Empty(t, ParseReportUtilization(nil))
+}
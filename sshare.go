@@ -16,71 +16,104 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>. */
 package main
 
 import (
-        "io/ioutil"
-        "os/exec"
-        "log"
+        "math"
         "strings"
         "strconv"
         "github.com/prometheus/client_golang/prometheus"
 )
 
 func FairShareData() []byte {
-        cmd := exec.Command( "sshare", "-n", "-P", "-o", "account,fairshare" )
-        stdout, err := cmd.StdoutPipe()
-        if err != nil {
-                log.Fatal(err)
-        }
-        if err := cmd.Start(); err != nil {
-                log.Fatal(err)
-        }
-        out, _ := ioutil.ReadAll(stdout)
-        if err := cmd.Wait(); err != nil {
-                log.Fatal(err)
-        }
-        return out
+        return CollectorData("fairshare", "sshare", "-n", "-P", "-a", "-o", "account,user,rawshares,normshares,rawusage,fairshare")
 }
 
+// Shares, usage and fairshare factor of an account or of a user of an account.
+// Values sshare leaves empty, e.g. the fairshare factor of the accounts with
+// the Fair Tree algorithm or the "parent" shares of a user, are NaN.
 type FairShareMetrics struct {
+        rawShares float64
+        normShares float64
+        rawUsage float64
         fairshare float64
 }
 
-func ParseFairShareMetrics() map[string]*FairShareMetrics {
-        accounts := make(map[string]*FairShareMetrics)
-        lines := strings.Split(string(FairShareData()), "\n")
-        for _, line := range lines {
-                if ! strings.HasPrefix(line,"  ") {
-                        if strings.Contains(line,"|") {
-                                account := strings.Trim(strings.Split(line,"|")[0]," ")
-                                _,key := accounts[account]
-                                if !key {
-                                        accounts[account] = &FairShareMetrics{0}
-                                }
-                                fairshare,_ := strconv.ParseFloat(strings.Split(line,"|")[1],64)
-                                accounts[account].fairshare = fairshare
-                        }
+// parseShareValue returns NaN for the values sshare leaves empty
+func parseShareValue(value string) float64 {
+        v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+        if err != nil {
+                return math.NaN()
+        }
+        return v
+}
+
+// ParseFairShareMetrics takes the pipe-delimited output of sshare with the
+// account, user, raw shares, normalized shares, raw usage and fairshare factor.
+// It returns the metrics per account and user, the account itself has the
+// empty user.
+func ParseFairShareMetrics(input []byte) map[string]map[string]*FairShareMetrics {
+        accounts := make(map[string]map[string]*FairShareMetrics)
+        for _, line := range strings.Split(string(input), "\n") {
+                fields := strings.Split(line, "|")
+                if len(fields) < 6 {
+                        continue
+                }
+                // sshare indents the accounts by their depth in the tree
+                account := strings.TrimSpace(fields[0])
+                user := strings.TrimSpace(fields[1])
+                if _, ok := accounts[account]; !ok {
+                        accounts[account] = make(map[string]*FairShareMetrics)
+                }
+                accounts[account][user] = &FairShareMetrics{
+                        rawShares: parseShareValue(fields[2]),
+                        normShares: parseShareValue(fields[3]),
+                        rawUsage: parseShareValue(fields[4]),
+                        fairshare: parseShareValue(fields[5]),
                 }
         }
         return accounts
 }
 
+/*
+ * Implement the Prometheus Collector interface and feed the
+ * Slurm share metrics into it.
+ * https://godoc.org/github.com/prometheus/client_golang/prometheus#Collector
+ */
+
 type FairShareCollector struct {
         fairshare *prometheus.Desc
+        rawShares *prometheus.Desc
+        normShares *prometheus.Desc
+        rawUsage *prometheus.Desc
 }
 
 func NewFairShareCollector() *FairShareCollector {
-        labels := []string{"account"}
+        labels := []string{"account","user"}
         return &FairShareCollector{
                 fairshare: prometheus.NewDesc("slurm_account_fairshare","FairShare for account" , labels,nil),
+                rawShares: prometheus.NewDesc("slurm_account_raw_shares","Shares assigned to the account or user" , labels,nil),
+                normShares: prometheus.NewDesc("slurm_account_norm_shares","Shares of the account or user normalized to the total shares" , labels,nil),
+                rawUsage: prometheus.NewDesc("slurm_account_raw_usage","Decayed usage of the account or user in CPU-seconds" , labels,nil),
         }
 }
 
 func (fsc *FairShareCollector) Describe(ch chan<- *prometheus.Desc) {
         ch <- fsc.fairshare
+        ch <- fsc.rawShares
+        ch <- fsc.normShares
+        ch <- fsc.rawUsage
 }
 
 func (fsc *FairShareCollector) Collect(ch chan<- prometheus.Metric) {
-        fsm := ParseFairShareMetrics()
-        for f := range fsm {
-                ch <- prometheus.MustNewConstMetric(fsc.fairshare, prometheus.GaugeValue, fsm[f].fairshare, f)
+        for account, users := range ParseFairShareMetrics(FairShareData()) {
+                for user, fm := range users {
+                        emit := func(desc *prometheus.Desc, value float64) {
+                                if !math.IsNaN(value) {
+                                        ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, account, user)
+                                }
+                        }
+                        emit(fsc.fairshare, fm.fairshare)
+                        emit(fsc.rawShares, fm.rawShares)
+                        emit(fsc.normShares, fm.normShares)
+                        emit(fsc.rawUsage, fm.rawUsage)
+                }
         }
 }
@@ -0,0 +1,47 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFairShareMetrics(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/sshare.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	accounts := ParseFairShareMetrics(data)
+
+	assert.Len(t, accounts, 3)
+	assert.Equal(t, 40.0, accounts["physics"][""].rawShares)
+	assert.Equal(t, 8000000.0, accounts["physics"][""].rawUsage)
+	// Fair Tree has no fairshare factor for the accounts
+	assert.True(t, math.IsNaN(accounts["physics"][""].fairshare))
+	assert.Equal(t, 0.25, accounts["physics"]["alice"].fairshare)
+	assert.Equal(t, 0.5, accounts["physics"]["bob"].normShares)
+	assert.Equal(t, 2000000.0, accounts["physics"]["bob"].rawUsage)
+	// The root user is no part of the root account
+	assert.Equal(t, 1.0, accounts["root"]["root"].fairshare)
+	assert.True(t, math.IsNaN(accounts["root"][""].rawShares))
+	// Users with the shares of their parent account
+	assert.True(t, math.IsNaN(accounts["chemistry"]["carol"].rawShares))
+	assert.Equal(t, 0.6, accounts["chemistry"]["carol"].normShares)
+}
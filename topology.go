@@ -0,0 +1,103 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Network switch of the Slurm topology
+type TopologySwitch struct {
+	level    string
+	nodes    string
+	switches string
+}
+
+// Execute the scontrol command to read the network topology
+func TopologyData() []byte {
+	return CollectorData("topology", "scontrol", "show", "topology")
+}
+
+// ParseTopology takes the output of scontrol show topology
+// It returns the switches keyed by SwitchName, lines without
+// a SwitchName (e.g. "No topology information available") are ignored
+func ParseTopology(input []byte) map[string]*TopologySwitch {
+	switches := make(map[string]*TopologySwitch)
+	for _, line := range strings.Split(string(input), "\n") {
+		record := ParseScontrolRecord(line)
+		name, ok := record["SwitchName"]
+		if !ok {
+			continue
+		}
+		switches[name] = &TopologySwitch{
+			level:    record["Level"],
+			nodes:    record["Nodes"],
+			switches: record["Switches"],
+		}
+	}
+	return switches
+}
+
+// ParseTopologyLinks returns the parent switch of every switch,
+// from the (hostlist) Switches field of the parent
+func ParseTopologyLinks(switches map[string]*TopologySwitch) map[string]string {
+	parents := make(map[string]string)
+	for parent, sw := range switches {
+		if sw.switches == "" {
+			continue
+		}
+		for _, child := range ExpandHostlist(sw.switches) {
+			parents[child] = parent
+		}
+	}
+	return parents
+}
+
+/*
+ * Implement the Prometheus Collector interface and feed the
+ * Slurm topology metrics into it.
+ * https://godoc.org/github.com/prometheus/client_golang/prometheus#Collector
+ */
+
+func NewTopologyCollector() *TopologyCollector {
+	return &TopologyCollector{
+		switchInfo: prometheus.NewDesc("slurm_topology_switch_info", "Network switch of the Slurm topology with its level and nodes", []string{"switch", "level", "nodes"}, nil),
+		switchLink: prometheus.NewDesc("slurm_topology_switch_link", "Link of a network switch to its parent switch", []string{"switch", "parent"}, nil),
+	}
+}
+
+type TopologyCollector struct {
+	switchInfo *prometheus.Desc
+	switchLink *prometheus.Desc
+}
+
+// Send all metric descriptions
+func (tc *TopologyCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- tc.switchInfo
+	ch <- tc.switchLink
+}
+
+func (tc *TopologyCollector) Collect(ch chan<- prometheus.Metric) {
+	switches := ParseTopology(TopologyData())
+	for name, sw := range switches {
+		ch <- prometheus.MustNewConstMetric(tc.switchInfo, prometheus.GaugeValue, 1, name, sw.level, sw.nodes)
+	}
+	for name, parent := range ParseTopologyLinks(switches) {
+		ch <- prometheus.MustNewConstMetric(tc.switchLink, prometheus.GaugeValue, 1, name, parent)
+	}
+}
@@ -0,0 +1,39 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopology(t *testing.T) {
+	data, err := ioutil.ReadFile("test_data/scontrol_topology.txt")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	switches := ParseTopology(data)
+
+	assert.Len(t, switches, 3)
+	assert.Equal(t, "0", switches["s0"].level)
+	assert.Equal(t, "node[01-16]", switches["s0"].nodes)
+	assert.Equal(t, "1", switches["top"].level)
+	assert.Equal(t, map[string]string{"s0": "top", "s1": "top"}, ParseTopologyLinks(switches))
+
+	assert.Empty(t, ParseTopology([]byte("No topology information available\n")))
+}
@@ -0,0 +1,48 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ParseTRES takes a Slurm TRES string, e.g.
+//
+//	billing=30,cpu=16,gres/gpu:a100=2,gres/gpu=2,mem=100G,node=1
+//
+// It returns the amount of every resource, memory is converted to megabytes
+func ParseTRES(input string) map[string]float64 {
+	tres := make(map[string]float64)
+	for _, resource := range strings.Split(strings.TrimSpace(input), ",") {
+		kv := strings.SplitN(resource, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		if kv[0] == "mem" {
+			mem, err := ParseMemory(kv[1])
+			if err == nil {
+				tres[kv[0]] = float64(mem)
+			}
+			continue
+		}
+		value, err := strconv.ParseFloat(kv[1], 64)
+		if err == nil {
+			tres[kv[0]] = value
+		}
+	}
+	return tres
+}
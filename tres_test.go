@@ -0,0 +1,37 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTRES(t *testing.T) {
+	tres := ParseTRES("billing=30,cpu=16,gres/gpu:a100=2,gres/gpu=2,mem=100G,node=1")
+	assert.Equal(t, map[string]float64{
+		"billing":       30,
+		"cpu":           16,
+		"gres/gpu:a100": 2,
+		"gres/gpu":      2,
+		"mem":           102400,
+		"node":          1,
+	}, tres)
+
+	assert.Empty(t, ParseTRES(""))
+	assert.Empty(t, ParseTRES("(null)"))
+}
@@ -0,0 +1,173 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+// VersionData executes sinfo to read the version of Slurm
+func VersionData() ([]byte, error) {
+	return RunCommand("version", "sinfo", "--version")
+}
+
+// ParseVersion extracts the version from the output of sinfo --version,
+// e.g. "22.05.8" from "slurm 22.05.8"
+func ParseVersion(input []byte) string {
+	fields := strings.Fields(string(input))
+	if len(fields) != 2 || fields[0] != "slurm" {
+		return ""
+	}
+	return fields[1]
+}
+
+// DetectSlurmVersion returns the version of Slurm, empty if sinfo fails.
+// It is only read once at startup, an upgrade restarts the exporter anyway.
+func DetectSlurmVersion() string {
+	out, err := VersionData()
+	if err != nil {
+		log.Warnf("Unable to detect the Slurm version: %v", err)
+		return ""
+	}
+	return ParseVersion(out)
+}
+
+// Version of Slurm detected at startup, empty if unknown
+var slurmVersion string
+
+// Supported releases of Slurm with the version of their slurmrestd API
+var slurmReleases = map[string]string{
+	"21.08": "v0.0.37",
+	"22.05": "v0.0.38",
+	"23.02": "v0.0.39",
+	"23.11": "v0.0.40",
+	"24.05": "v0.0.41",
+	"24.11": "v0.0.42",
+}
+
+// API of slurmrestd used when the release of Slurm is unknown
+const defaultRESTAPIVersion = "v0.0.40"
+
+// SlurmRelease returns the release of a version, e.g. "22.05" of "22.05.8"
+func SlurmRelease(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// releaseNumber returns a release as a number for comparisons, e.g. 2205
+// for "22.05", 0 if the release is not of this form
+func releaseNumber(release string) int {
+	parts := strings.Split(release, ".")
+	if len(parts) != 2 || len(parts[1]) != 2 {
+		return 0
+	}
+	year, err1 := strconv.Atoi(parts[0])
+	month, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return 0
+	}
+	return year*100 + month
+}
+
+// SupportedReleases returns the supported releases of Slurm in order
+func SupportedReleases() []string {
+	var releases []string
+	for release := range slurmReleases {
+		releases = append(releases, release)
+	}
+	sort.Slice(releases, func(i, j int) bool { return releaseNumber(releases[i]) < releaseNumber(releases[j]) })
+	return releases
+}
+
+// ParserVersion returns the release of Slurm whose output is parsed,
+// -parser-version if set or else the release of the detected version.
+// It is empty if neither is known.
+func ParserVersion(detected string) (string, error) {
+	if *parserVersion == "" {
+		return SlurmRelease(detected), nil
+	}
+	release := SlurmRelease(*parserVersion)
+	if _, ok := slurmReleases[release]; !ok {
+		return "", fmt.Errorf("unsupported release %q, expected one of %s", *parserVersion, strings.Join(SupportedReleases(), ", "))
+	}
+	return release, nil
+}
+
+// RESTAPIVersion returns the version of the slurmrestd API of a release,
+// v0.0.40 for unknown releases
+func RESTAPIVersion(release string) string {
+	if api, ok := slurmReleases[release]; ok {
+		return api
+	}
+	return defaultRESTAPIVersion
+}
+
+// LegacyNodeSchema reports if the JSON nodes of a release of Slurm list
+// the flags of their state apart, as before 23.02. An unknown release
+// uses the current schema.
+func LegacyNodeSchema(release string) bool {
+	number := releaseNumber(release)
+	return number > 0 && number < 2302
+}
+
+// CheckFormat reports if a release of Slurm supports a -slurm.format,
+// sinfo --json is available since 21.08. An unknown release supports all.
+func CheckFormat(format, release string) error {
+	if format == "json" && release != "" && releaseNumber(release) < 2108 {
+		return fmt.Errorf("sinfo --json requires Slurm 21.08 or later, not %s", release)
+	}
+	return nil
+}
+
+/*
+ * Implement the Prometheus Collector interface and feed the
+ * Slurm version into it.
+ * https://godoc.org/github.com/prometheus/client_golang/prometheus#Collector
+ */
+
+func NewVersionCollector(version string) *VersionCollector {
+	return &VersionCollector{
+		version: version,
+		info:    prometheus.NewDesc("slurm_version_info", "Version of Slurm, always 1", []string{"version"}, nil),
+	}
+}
+
+type VersionCollector struct {
+	version string
+	info    *prometheus.Desc
+}
+
+// Send all metric descriptions
+func (vc *VersionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- vc.info
+}
+
+// Collect emits nothing if the version could not be detected
+func (vc *VersionCollector) Collect(ch chan<- prometheus.Metric) {
+	if vc.version == "" {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(vc.info, prometheus.GaugeValue, 1, vc.version)
+}
@@ -0,0 +1,118 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVersion(t *testing.T) {
+	assert.Equal(t, "22.05.8", ParseVersion([]byte("slurm 22.05.8\n")))
+	assert.Equal(t, "", ParseVersion([]byte("")))
+	assert.Equal(t, "", ParseVersion([]byte("sinfo: error: unable to load plugin\n")))
+}
+
+func TestDetectSlurmVersion(t *testing.T) {
+	calls := stubCommands(t, map[string]string{"sinfo": "test_data/sinfo_version.txt"})
+	assert.Equal(t, "23.11.4", DetectSlurmVersion())
+	assert.Equal(t, []string{"--version"}, calls["sinfo"])
+
+	// A failing sinfo leaves the version unknown
+	stubCommands(t, map[string]string{})
+	assert.Equal(t, "", DetectSlurmVersion())
+}
+
+func TestVersionCollector(t *testing.T) {
+	expected := `
+# HELP slurm_version_info Version of Slurm, always 1
+# TYPE slurm_version_info gauge
+slurm_version_info{version="22.05.8"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(NewVersionCollector("22.05.8"), strings.NewReader(expected)))
+
+	// An unknown version is left out
+	assert.Equal(t, 0, testutil.CollectAndCount(NewVersionCollector("")))
+}
+
+func TestParserVersion(t *testing.T) {
+	defer func(version string) { *parserVersion = version }(*parserVersion)
+
+	// Without the flag the detected version is parsed
+	*parserVersion = ""
+	release, err := ParserVersion("23.02.7")
+	assert.NoError(t, err)
+	assert.Equal(t, "23.02", release)
+	release, err = ParserVersion("")
+	assert.NoError(t, err)
+	assert.Equal(t, "", release)
+	assert.Equal(t, "v0.0.39", RESTAPIVersion("23.02"))
+	assert.Equal(t, "v0.0.40", RESTAPIVersion(""))
+
+	// The flag wins over a detected version guessed wrong
+	*parserVersion = "22.05"
+	release, err = ParserVersion("23.02.7")
+	assert.NoError(t, err)
+	assert.Equal(t, "22.05", release)
+	assert.Equal(t, "v0.0.38", RESTAPIVersion(release))
+
+	*parserVersion = "24.05.3"
+	release, err = ParserVersion("")
+	assert.NoError(t, err)
+	assert.Equal(t, "v0.0.41", RESTAPIVersion(release))
+
+	*parserVersion = "20.11"
+	_, err = ParserVersion("23.02.7")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "21.08, 22.05, 23.02, 23.11, 24.05, 24.11")
+}
+
+func TestCheckFormat(t *testing.T) {
+	assert.NoError(t, CheckFormat("json", "21.08"))
+	assert.NoError(t, CheckFormat("json", ""))
+	assert.NoError(t, CheckFormat("text", "20.11"))
+	assert.Error(t, CheckFormat("json", "20.11"))
+}
+
+func TestParserVersionNodes(t *testing.T) {
+	defer func(version string) { *parserVersion = version }(*parserVersion)
+	data, err := ioutil.ReadFile("test_data/slurmrestd_nodes_22.05.json")
+	if err != nil {
+		t.Fatalf("Can not open test data: %v", err)
+	}
+	parse := func(version string) *NodeMetrics {
+		*parserVersion = version
+		release, err := ParserVersion("23.11.4")
+		assert.NoError(t, err)
+		nodes, err := ParseRESTNodes(data, release)
+		assert.NoError(t, err)
+		return nodes["cpu01"]
+	}
+
+	// 22.05 lists the flags of the state and the free memory apart
+	node := parse("22.05")
+	assert.Equal(t, "idle+drain", node.nodeStatus)
+	assert.Equal(t, uint64(240000), node.memFree)
+
+	// 23.11 lists them in state and free_mem
+	node = parse("23.11")
+	assert.Equal(t, "idle", node.nodeStatus)
+	assert.Equal(t, uint64(0), node.memFree)
+}
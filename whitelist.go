@@ -0,0 +1,130 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The client library does not export the name of a Desc, only its string form
+var descNameRe = regexp.MustCompile(`fqName: "([^"]*)"`)
+
+// DescName returns the fully-qualified metric name of a Desc
+func DescName(desc *prometheus.Desc) string {
+	match := descNameRe.FindStringSubmatch(desc.String())
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// ParseWhitelist takes a comma separated list of metric names
+func ParseWhitelist(list string) map[string]bool {
+	names := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// WhitelistRegisterer wraps every registered collector so only the
+// whitelisted metrics are described and collected
+type WhitelistRegisterer struct {
+	prometheus.Registerer
+	allowed map[string]bool
+	known   map[string]bool
+}
+
+func NewWhitelistRegisterer(allowed map[string]bool, r prometheus.Registerer) *WhitelistRegisterer {
+	return &WhitelistRegisterer{Registerer: r, allowed: allowed, known: make(map[string]bool)}
+}
+
+func (w *WhitelistRegisterer) Register(c prometheus.Collector) error {
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(descs)
+		close(descs)
+	}()
+	for desc := range descs {
+		w.known[DescName(desc)] = true
+	}
+	return w.Registerer.Register(&whitelistCollector{collector: c, allowed: w.allowed})
+}
+
+func (w *WhitelistRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := w.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterRuntimeCollectors moves the Go and process collectors, which the
+// client library registers by default, behind the whitelist as well
+func (w *WhitelistRegisterer) RegisterRuntimeCollectors() {
+	w.Registerer.Unregister(prometheus.NewGoCollector())
+	w.Registerer.Unregister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	w.MustRegister(prometheus.NewGoCollector(), prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+}
+
+// Unknown returns the whitelisted names not provided by any registered collector
+func (w *WhitelistRegisterer) Unknown() []string {
+	var unknown []string
+	for name := range w.allowed {
+		if !w.known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+type whitelistCollector struct {
+	collector prometheus.Collector
+	allowed   map[string]bool
+}
+
+func (wc *whitelistCollector) Describe(ch chan<- *prometheus.Desc) {
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		wc.collector.Describe(descs)
+		close(descs)
+	}()
+	for desc := range descs {
+		if wc.allowed[DescName(desc)] {
+			ch <- desc
+		}
+	}
+}
+
+func (wc *whitelistCollector) Collect(ch chan<- prometheus.Metric) {
+	metrics := make(chan prometheus.Metric)
+	go func() {
+		wc.collector.Collect(metrics)
+		close(metrics)
+	}()
+	for metric := range metrics {
+		if wc.allowed[DescName(metric.Desc())] {
+			ch <- metric
+		}
+	}
+}
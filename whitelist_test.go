@@ -0,0 +1,71 @@
+/* Copyright 2026 Victor Penso, Matteo Dessalvi
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>. */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsWhitelist(t *testing.T) {
+	alloc := prometheus.NewGauge(prometheus.GaugeOpts{Name: "slurm_node_cpu_alloc", Help: "test"})
+	idle := prometheus.NewGauge(prometheus.GaugeOpts{Name: "slurm_node_cpu_idle", Help: "test"})
+
+	registry := prometheus.NewRegistry()
+	w := NewWhitelistRegisterer(ParseWhitelist("slurm_node_cpu_alloc, slurm_does_not_exist"), registry)
+	w.MustRegister(alloc, idle)
+	assert.Equal(t, []string{"slurm_does_not_exist"}, w.Unknown())
+
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	var names []string
+	for _, family := range families {
+		names = append(names, family.GetName())
+	}
+	assert.Equal(t, []string{"slurm_node_cpu_alloc"}, names)
+}
+
+func TestMetricsWhitelistRuntime(t *testing.T) {
+	names := func(registry *prometheus.Registry) map[string]bool {
+		families, err := registry.Gather()
+		assert.NoError(t, err)
+		names := make(map[string]bool)
+		for _, family := range families {
+			names[family.GetName()] = true
+		}
+		return names
+	}
+
+	// Registered like in the default registry
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewGoCollector(), prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	assert.True(t, names(registry)["go_goroutines"])
+
+	w := NewWhitelistRegisterer(ParseWhitelist("slurm_node_cpu_alloc"), registry)
+	w.RegisterRuntimeCollectors()
+	assert.False(t, names(registry)["go_goroutines"])
+	assert.False(t, names(registry)["process_start_time_seconds"])
+
+	// Unless whitelisted
+	registry = prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewGoCollector())
+	w = NewWhitelistRegisterer(ParseWhitelist("go_goroutines"), registry)
+	w.RegisterRuntimeCollectors()
+	assert.Equal(t, map[string]bool{"go_goroutines": true}, names(registry))
+	assert.Empty(t, w.Unknown())
+}